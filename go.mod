@@ -3,6 +3,7 @@ module github.com/aliwert/go-wolf
 go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/stretchr/testify v1.10.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,65 @@
+// Package codec provides the JSON codec shared by request binding and
+// response rendering
+package codec
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Encoder is the streaming encoder returned by a Codec
+type Encoder interface {
+	Encode(v interface{}) error
+	SetIndent(prefix, indent string)
+}
+
+// Decoder is the streaming decoder returned by a Codec
+type Decoder interface {
+	Decode(v interface{}) error
+}
+
+// Codec abstracts the JSON implementation used by the request binders and
+// the response helpers
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Std is the default Codec backed by encoding/json. Custom codecs may
+// embed it to replace only some of its methods.
+type Std struct{}
+
+func (Std) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (Std) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (Std) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func (Std) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+// jsonCodec is the codec used by both packages
+var jsonCodec Codec = Std{}
+
+// SetJSON replaces the JSON codec used for request binding and responses.
+// Passing nil restores the encoding/json default.
+func SetJSON(codec Codec) {
+	if codec == nil {
+		codec = Std{}
+	}
+	jsonCodec = codec
+}
+
+// JSON returns the JSON codec currently in use
+func JSON() Codec {
+	return jsonCodec
+}
@@ -0,0 +1,42 @@
+package codec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type upperCodec struct {
+	Std
+}
+
+func (upperCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(`"UPPER"`), nil
+}
+
+func TestSetJSON(t *testing.T) {
+	SetJSON(upperCodec{})
+	defer SetJSON(nil)
+
+	data, err := JSON().Marshal("lower")
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(data) != `"UPPER"` {
+		t.Errorf("expected the custom codec to marshal, got %s", data)
+	}
+
+	// Methods the custom codec doesn't replace fall back to Std
+	var buf bytes.Buffer
+	if err := JSON().NewEncoder(&buf).Encode("lower"); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if strings.TrimSpace(buf.String()) != `"lower"` {
+		t.Errorf("expected the embedded encoder, got %s", buf.String())
+	}
+
+	SetJSON(nil)
+	if _, ok := JSON().(Std); !ok {
+		t.Error("expected SetJSON(nil) to restore the default codec")
+	}
+}
@@ -1,28 +1,293 @@
 package request
 
 import (
+	"bytes"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
+
+	"github.com/aliwert/go-wolf/pkg/codec"
 )
 
-// BindJSON binds the request body to a struct using JSON
+// MaxJSONSize is the largest JSON body BindJSON will read, in bytes.
+// Zero disables the limit.
+var MaxJSONSize int64
+
+// ErrRequestTooLarge is returned when a request body exceeds its size limit
+var ErrRequestTooLarge = errors.New("request body too large")
+
+// ErrEmptyBody is returned by BindJSONRequired when the request body is empty
+var ErrEmptyBody = errors.New("request body is empty")
+
+// maxJSONDepth is the deepest object/array nesting BindJSON accepts.
+// Zero disables the check.
+var maxJSONDepth int
+
+// ErrJSONTooDeep is returned when a JSON body nests deeper than allowed
+var ErrJSONTooDeep = errors.New("JSON nesting too deep")
+
+// SetMaxJSONDepth sets the deepest object/array nesting BindJSON accepts.
+// Zero disables the check.
+func SetMaxJSONDepth(n int) {
+	if n < 0 {
+		n = 0
+	}
+	maxJSONDepth = n
+}
+
+// BindError reports that request data could not be decoded into the
+// target, as opposed to ValidationErrors which reports decoded but
+// invalid data
+type BindError struct {
+	Source string // json, xml, yaml, toml, form, query, path or header
+	Err    error
+}
+
+// Error implements the error interface
+func (e *BindError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// BindErrors collects the failures of several binding sources, as
+// returned by BindAll
+type BindErrors []*BindError
+
+// Error implements the error interface
+func (e BindErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Source + ": " + err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// BindJSON binds the request body to a struct using JSON. An empty body
+// binds no data; obj is still validated so required fields fail.
 func BindJSON(r *http.Request, obj interface{}) error {
+	return bindJSON(r, obj, false)
+}
+
+// BindJSONRequired binds like BindJSON but returns ErrEmptyBody when the
+// request has no body
+func BindJSONRequired(r *http.Request, obj interface{}) error {
+	return bindJSON(r, obj, true)
+}
+
+func bindJSON(r *http.Request, obj interface{}, required bool) error {
 	if r.Body == nil {
 		return fmt.Errorf("request body is nil")
 	}
 
-	decoder := json.NewDecoder(r.Body)
+	if MaxJSONSize > 0 {
+		if r.ContentLength > MaxJSONSize {
+			return fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrRequestTooLarge, r.ContentLength, MaxJSONSize)
+		}
+		r.Body = http.MaxBytesReader(nil, r.Body, MaxJSONSize)
+	}
+
+	if maxJSONDepth > 0 {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return fmt.Errorf("%w: limit is %d bytes", ErrRequestTooLarge, MaxJSONSize)
+			}
+			return &BindError{Source: "json", Err: fmt.Errorf("failed to read body: %w", err)}
+		}
+		if err := checkJSONDepth(body, maxJSONDepth); err != nil {
+			return &BindError{Source: "json", Err: err}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	decoder := newJSONDecoder(r.Body)
 	if err := decoder.Decode(obj); err != nil {
-		return fmt.Errorf("failed to decode JSON: %w", err)
+		if errors.Is(err, io.EOF) {
+			if required {
+				return &BindError{Source: "json", Err: ErrEmptyBody}
+			}
+			return Validate(obj)
+		}
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return fmt.Errorf("%w: limit is %d bytes", ErrRequestTooLarge, MaxJSONSize)
+		}
+		return &BindError{Source: "json", Err: fmt.Errorf("failed to decode JSON: %w", err)}
 	}
 
+	Normalize(obj)
 	return Validate(obj)
 }
 
+// checkJSONDepth scans data token by token and fails once object/array
+// nesting exceeds max. Syntax errors are left for the decoder to report.
+func checkJSONDepth(data []byte, max int) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+			if depth > max {
+				return fmt.Errorf("%w: exceeds maximum depth of %d", ErrJSONTooDeep, max)
+			}
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+}
+
+// DecodeJSONArray decodes a JSON array body one element at a time without
+// buffering it. each is called once per element with a decode function
+// that decodes the element into v, then normalizes and validates it.
+// Returning an error from each stops decoding.
+func DecodeJSONArray(r *http.Request, each func(decode func(v interface{}) error) error) error {
+	if r.Body == nil {
+		return fmt.Errorf("request body is nil")
+	}
+
+	if MaxJSONSize > 0 {
+		if r.ContentLength > MaxJSONSize {
+			return fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrRequestTooLarge, r.ContentLength, MaxJSONSize)
+		}
+		r.Body = http.MaxBytesReader(nil, r.Body, MaxJSONSize)
+	}
+
+	// Token-level iteration is not part of Codec, so this uses encoding/json
+	decoder := json.NewDecoder(r.Body)
+	if jsonUseNumber {
+		decoder.UseNumber()
+	}
+
+	tok, err := decoder.Token()
+	if err != nil {
+		return &BindError{Source: "json", Err: fmt.Errorf("failed to decode JSON: %w", err)}
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return &BindError{Source: "json", Err: fmt.Errorf("expected JSON array")}
+	}
+
+	var decoded bool
+	decode := func(v interface{}) error {
+		decoded = true
+		if err := decoder.Decode(v); err != nil {
+			return &BindError{Source: "json", Err: fmt.Errorf("failed to decode JSON: %w", err)}
+		}
+		Normalize(v)
+		return Validate(v)
+	}
+
+	for decoder.More() {
+		decoded = false
+		if err := each(decode); err != nil {
+			return err
+		}
+
+		// Skip elements each chose not to decode
+		if !decoded {
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return &BindError{Source: "json", Err: fmt.Errorf("failed to decode JSON: %w", err)}
+			}
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return &BindError{Source: "json", Err: fmt.Errorf("failed to decode JSON: %w", err)}
+	}
+	return nil
+}
+
+// BindJSONMergePatch applies the request body as an RFC 7386 JSON merge
+// patch to original and stores the validated result in target, which must
+// be a pointer and may be original itself. Fields present in the patch
+// overwrite, fields set to null are reset, and absent fields are kept.
+func BindJSONMergePatch(r *http.Request, original, target interface{}) error {
+	if r.Body == nil {
+		return fmt.Errorf("request body is nil")
+	}
+
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer")
+	}
+
+	// Both documents keep numbers as json.Number, so large integers the
+	// patch doesn't touch survive the round trip exactly
+	var patch interface{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&patch); err != nil {
+		return &BindError{Source: "json", Err: fmt.Errorf("failed to decode merge patch: %w", err)}
+	}
+
+	data, err := codec.JSON().Marshal(original)
+	if err != nil {
+		return fmt.Errorf("failed to encode original: %w", err)
+	}
+	var doc interface{}
+	decoder = json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return fmt.Errorf("failed to encode original: %w", err)
+	}
+
+	merged, err := codec.JSON().Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return fmt.Errorf("failed to encode merged document: %w", err)
+	}
+
+	// Start from zero so fields removed by the patch don't keep old values
+	rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+	if err := codec.JSON().Unmarshal(merged, target); err != nil {
+		return &BindError{Source: "json", Err: fmt.Errorf("failed to apply merge patch: %w", err)}
+	}
+
+	Normalize(target)
+	return Validate(target)
+}
+
+// mergePatch applies patch to target following RFC 7386
+func mergePatch(target, patch interface{}) interface{} {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetMap, ok := target.(map[string]interface{})
+	if !ok {
+		targetMap = make(map[string]interface{})
+	}
+
+	for key, value := range patchMap {
+		if value == nil {
+			delete(targetMap, key)
+			continue
+		}
+		targetMap[key] = mergePatch(targetMap[key], value)
+	}
+	return targetMap
+}
+
 // BindXML binds the request body to a struct using XML
 func BindXML(r *http.Request, obj interface{}) error {
 	if r.Body == nil {
@@ -31,9 +296,10 @@ func BindXML(r *http.Request, obj interface{}) error {
 
 	decoder := xml.NewDecoder(r.Body)
 	if err := decoder.Decode(obj); err != nil {
-		return fmt.Errorf("failed to decode XML: %w", err)
+		return &BindError{Source: "xml", Err: fmt.Errorf("failed to decode XML: %w", err)}
 	}
 
+	Normalize(obj)
 	return Validate(obj)
 }
 
@@ -45,12 +311,59 @@ func BindYAML(r *http.Request, obj interface{}) error {
 
 	decoder := yaml.NewDecoder(r.Body)
 	if err := decoder.Decode(obj); err != nil {
-		return fmt.Errorf("failed to decode YAML: %w", err)
+		return &BindError{Source: "yaml", Err: fmt.Errorf("failed to decode YAML: %w", err)}
 	}
 
+	Normalize(obj)
 	return Validate(obj)
 }
 
+// BindTOML binds the request body to a struct using TOML
+func BindTOML(r *http.Request, obj interface{}) error {
+	if r.Body == nil {
+		return fmt.Errorf("request body is nil")
+	}
+
+	if _, err := toml.NewDecoder(r.Body).Decode(obj); err != nil {
+		return &BindError{Source: "toml", Err: fmt.Errorf("failed to decode TOML: %w", err)}
+	}
+
+	Normalize(obj)
+	return Validate(obj)
+}
+
+// Binder binds a request into obj using a specific source
+type Binder interface {
+	Bind(r *http.Request, obj interface{}) error
+}
+
+// BinderFunc adapts a bind function to the Binder interface
+type BinderFunc func(r *http.Request, obj interface{}) error
+
+// Bind calls f(r, obj)
+func (f BinderFunc) Bind(r *http.Request, obj interface{}) error {
+	return f(r, obj)
+}
+
+// Built-in binders, for forcing a source regardless of content type
+var (
+	JSONBinder   Binder = BinderFunc(BindJSON)
+	XMLBinder    Binder = BinderFunc(BindXML)
+	YAMLBinder   Binder = BinderFunc(BindYAML)
+	TOMLBinder   Binder = BinderFunc(BindTOML)
+	FormBinder   Binder = BinderFunc(BindForm)
+	QueryBinder  Binder = BinderFunc(BindQuery)
+	HeaderBinder Binder = BinderFunc(BindHeader)
+)
+
+// BindWith binds the request using the given binder, ignoring content type
+func BindWith(r *http.Request, obj interface{}, binder Binder) error {
+	if binder == nil {
+		return fmt.Errorf("binder is nil")
+	}
+	return binder.Bind(r, obj)
+}
+
 // SmartBind automatically detects content type and binds accordingly
 func SmartBind(r *http.Request, obj interface{}) error {
 	contentType := GetContentType(r)
@@ -62,9 +375,91 @@ func SmartBind(r *http.Request, obj interface{}) error {
 		return BindXML(r, obj)
 	case IsYAML(r):
 		return BindYAML(r, obj)
+	case IsTOML(r):
+		return BindTOML(r, obj)
 	case IsForm(r):
 		return BindForm(r, obj)
 	default:
 		return fmt.Errorf("unsupported content type: %s", contentType)
 	}
 }
+
+// SmartBindTolerant binds like SmartBind, but when the body fails to
+// decode as its declared content type it sniffs the body and retries with
+// the matching binder, e.g. for clients that send a form body labeled as
+// JSON. Validation failures are not retried. If the retry also fails to
+// decode, the original error is returned. The body is buffered for the
+// retry, so MaxJSONSize bounds it whatever its content type.
+func SmartBindTolerant(r *http.Request, obj interface{}) error {
+	if r.Body == nil {
+		return SmartBind(r, obj)
+	}
+
+	if MaxJSONSize > 0 {
+		if r.ContentLength > MaxJSONSize {
+			return fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrRequestTooLarge, r.ContentLength, MaxJSONSize)
+		}
+		r.Body = http.MaxBytesReader(nil, r.Body, MaxJSONSize)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return fmt.Errorf("%w: limit is %d bytes", ErrRequestTooLarge, MaxJSONSize)
+		}
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	err = SmartBind(r, obj)
+	var bindErr *BindError
+	if err == nil || !errors.As(err, &bindErr) {
+		return err
+	}
+
+	source, contentType := sniffBody(body)
+	if source == "" || source == bindErr.Source {
+		return err
+	}
+
+	retry := r.Clone(r.Context())
+	retry.Header.Set("Content-Type", contentType)
+	retry.Body = io.NopCloser(bytes.NewReader(body))
+	retry.ContentLength = int64(len(body))
+	retry.Form, retry.PostForm = nil, nil
+
+	// Drop anything the failed attempt decoded before it gave up
+	if rv := reflect.ValueOf(obj); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+	}
+
+	retryErr := SmartBind(retry, obj)
+	if retryErr != nil && errors.As(retryErr, &bindErr) {
+		return err
+	}
+	return retryErr
+}
+
+// sniffBody guesses the format of a request body from its content,
+// returning the BindError source and content type of its binder
+func sniffBody(body []byte) (source, contentType string) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return "", ""
+	}
+
+	switch trimmed[0] {
+	case '{', '[':
+		return "json", "application/json"
+	case '<':
+		return "xml", "application/xml"
+	}
+
+	if bytes.IndexByte(trimmed, '=') > 0 && !bytes.ContainsAny(trimmed, " \t\r\n") {
+		if _, err := url.ParseQuery(string(trimmed)); err == nil {
+			return "form", "application/x-www-form-urlencoded"
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,31 @@
+package request
+
+import (
+	"io"
+
+	"github.com/aliwert/go-wolf/pkg/codec"
+)
+
+// jsonUseNumber makes the binders decode numbers into interface{} values
+// as json.Number instead of float64
+var jsonUseNumber bool
+
+// SetJSONUseNumber makes the JSON binders decode numbers held in
+// interface{} values, including map[string]interface{}, as json.Number so
+// large integers keep their precision. It applies to codecs whose decoder
+// has a UseNumber method, as encoding/json's does.
+func SetJSONUseNumber(enabled bool) {
+	jsonUseNumber = enabled
+}
+
+// newJSONDecoder returns a decoder from the current codec, honoring
+// SetJSONUseNumber
+func newJSONDecoder(r io.Reader) codec.Decoder {
+	decoder := codec.JSON().NewDecoder(r)
+	if jsonUseNumber {
+		if d, ok := decoder.(interface{ UseNumber() }); ok {
+			d.UseNumber()
+		}
+	}
+	return decoder
+}
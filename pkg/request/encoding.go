@@ -0,0 +1,39 @@
+package request
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// IsBase64 checks if s is padded standard base64
+func IsBase64(s string) bool {
+	if s == "" {
+		return false
+	}
+	_, err := base64.StdEncoding.Strict().DecodeString(s)
+	return err == nil
+}
+
+// IsBase64URL checks if s is URL-safe base64, with or without padding
+func IsBase64URL(s string) bool {
+	if s == "" {
+		return false
+	}
+	encoding := base64.RawURLEncoding
+	if strings.HasSuffix(s, "=") {
+		encoding = base64.URLEncoding
+	}
+	_, err := encoding.Strict().DecodeString(s)
+	return err == nil
+}
+
+// IsHex checks if s contains only hexadecimal digits
+func IsHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')) {
+			return false
+		}
+	}
+	return len(s) > 0
+}
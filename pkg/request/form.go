@@ -1,6 +1,7 @@
 package request
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -20,7 +21,7 @@ func BindQuery(r *http.Request, obj interface{}) error {
 // BindForm binds form data to a struct
 func BindForm(r *http.Request, obj interface{}) error {
 	if err := r.ParseForm(); err != nil {
-		return fmt.Errorf("failed to parse form: %w", err)
+		return &BindError{Source: "form", Err: fmt.Errorf("failed to parse form: %w", err)}
 	}
 
 	if err := bindValues(r.Form, obj, "form"); err != nil {
@@ -47,39 +48,58 @@ func BindHeader(r *http.Request, obj interface{}) error {
 	return bindValues(values, obj, "header")
 }
 
-// BindAll binds data from multiple sources to a struct
+// BindAll binds data from multiple sources to a struct. Every source is
+// attempted; if any fail, the failures are returned together as BindErrors
+// and validation is skipped.
 func BindAll(r *http.Request, params map[string]string, obj interface{}) error {
-	// Parse form first if applicable
+	var errs BindErrors
+	collect := func(source string, err error) {
+		if err == nil {
+			return
+		}
+		var bindErr *BindError
+		if errors.As(err, &bindErr) {
+			errs = append(errs, bindErr)
+			return
+		}
+		errs = append(errs, &BindError{Source: source, Err: err})
+	}
+
+	// Parse form first if applicable. Multipart values land in r.PostForm
+	// too, so it holds every body field.
 	if IsForm(r) {
-		if err := r.ParseForm(); err != nil {
-			return fmt.Errorf("failed to parse form: %w", err)
+		var err error
+		if strings.Contains(GetContentType(r), "multipart/form-data") {
+			err = r.ParseMultipartForm(32 << 20) // 32MB
+		} else {
+			err = r.ParseForm()
+		}
+		if err != nil {
+			collect("form", fmt.Errorf("failed to parse form: %w", err))
 		}
 	}
 
 	// Bind path parameters
 	if len(params) > 0 {
-		if err := BindPath(params, obj); err != nil {
-			return fmt.Errorf("failed to bind path params: %w", err)
-		}
+		collect("path", BindPath(params, obj))
 	}
 
-	// Bind query parameters
-	if err := BindQuery(r, obj); err != nil {
-		return fmt.Errorf("failed to bind query params: %w", err)
-	}
+	// Bind query parameters. BindQuery and BindForm also validate, so
+	// their binding step is called directly and validation runs once below.
+	collect("query", bindValues(r.URL.Query(), obj, "query"))
 
-	// Bind form data if present
-	if IsForm(r) && len(r.Form) > 0 {
-		if err := BindForm(r, obj); err != nil {
-			return fmt.Errorf("failed to bind form data: %w", err)
-		}
+	// Bind form data if present, from the body only: r.Form repeats the
+	// query, which is bound above
+	if IsForm(r) && len(r.PostForm) > 0 {
+		collect("form", bindValues(r.PostForm, obj, "form"))
 	}
 
-	// Bind headers if needed
-	if err := BindHeader(r, obj); err != nil {
-		return fmt.Errorf("failed to bind headers: %w", err)
-	}
+	// Bind headers
+	collect("header", BindHeader(r, obj))
 
+	if len(errs) > 0 {
+		return errs
+	}
 	return Validate(obj)
 }
 
@@ -101,6 +121,14 @@ func bindValues(values map[string][]string, obj interface{}, tag string) error {
 			continue
 		}
 
+		// Bind embedded structs, e.g. shared pagination fields, in place
+		if fieldType.Anonymous && field.Kind() == reflect.Struct && fieldType.Tag.Get(tag) == "" {
+			if err := bindValues(values, field.Addr().Interface(), tag); err != nil {
+				return err
+			}
+			continue
+		}
+
 		// Get tag name
 		tagName := fieldType.Tag.Get(tag)
 		if tagName == "" {
@@ -118,9 +146,16 @@ func bindValues(values map[string][]string, obj interface{}, tag string) error {
 			continue
 		}
 
+		raw := value[0]
+		if field.Kind() == reflect.String {
+			if normalizeTag := fieldType.Tag.Get("normalize"); normalizeTag != "" {
+				raw = normalizeString(raw, normalizeTag)
+			}
+		}
+
 		// Set field value based on type
-		if err := setFieldValue(field, value[0]); err != nil {
-			return fmt.Errorf("failed to set field %s: %w", fieldType.Name, err)
+		if err := setFieldValue(field, raw); err != nil {
+			return &BindError{Source: tag, Err: fmt.Errorf("failed to set field %s: %w", fieldType.Name, err)}
 		}
 	}
 
@@ -0,0 +1,29 @@
+package request
+
+import "strings"
+
+// IsLuhn checks if s passes the Luhn checksum, as used by credit card
+// numbers. Spaces and dashes are ignored.
+func IsLuhn(s string) bool {
+	s = strings.NewReplacer(" ", "", "-", "").Replace(s)
+	if len(s) < 2 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < len(s); i++ {
+		c := s[len(s)-1-i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		digit := int(c - '0')
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,168 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	// ErrTooManyFiles is returned when an upload carries more files than allowed
+	ErrTooManyFiles = errors.New("too many files")
+
+	// ErrFileTooLarge is returned when an uploaded file exceeds its size limit
+	ErrFileTooLarge = errors.New("file too large")
+
+	// ErrFileTypeNotAllowed is returned when an uploaded file's sniffed
+	// content type is not allowed
+	ErrFileTypeNotAllowed = errors.New("file type not allowed")
+)
+
+// MultipartLimits bounds a multipart upload. Zero values disable a limit.
+type MultipartLimits struct {
+	MaxFiles     int      // number of file parts
+	MaxFileSize  int64    // bytes per file
+	MaxFieldSize int64    // bytes per non-file field
+	MaxTotalSize int64    // bytes across all parts
+	AllowedTypes []string // sniffed media types, e.g. "image/png" or "image/*"
+}
+
+// UploadedFile is a file read by ParseMultipartBounded
+type UploadedFile struct {
+	Filename    string
+	ContentType string // sniffed from the content, not the part header
+	Size        int64
+	Data        []byte
+}
+
+// BoundedForm holds the fields and files of a multipart upload
+type BoundedForm struct {
+	Values url.Values
+	Files  map[string][]*UploadedFile
+}
+
+// ParseMultipartBounded streams a multipart body, enforcing limits as each
+// part is read so an oversized or disallowed upload is rejected without
+// reading the rest. Files are held in memory, so MaxFileSize should be set.
+func ParseMultipartBounded(r *http.Request, limits MultipartLimits) (*BoundedForm, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	form := &BoundedForm{
+		Values: make(url.Values),
+		Files:  make(map[string][]*UploadedFile),
+	}
+
+	var total int64
+	files := 0
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := part.FormName()
+		isFile := part.FileName() != ""
+
+		limit := limits.MaxFieldSize
+		if isFile {
+			files++
+			if limits.MaxFiles > 0 && files > limits.MaxFiles {
+				return nil, fmt.Errorf("%w: more than %d files", ErrTooManyFiles, limits.MaxFiles)
+			}
+			limit = limits.MaxFileSize
+		}
+
+		// Never read past whichever of the part and total limits is closer
+		budget := int64(-1)
+		if limit > 0 {
+			budget = limit
+		}
+		if limits.MaxTotalSize > 0 {
+			if remaining := limits.MaxTotalSize - total; budget < 0 || remaining < budget {
+				budget = remaining
+			}
+		}
+
+		// Closing a part drains it, so a rejected part is left unclosed
+		data, err := readPartLimited(part, budget)
+		if err != nil {
+			return nil, err
+		}
+
+		if limit > 0 && int64(len(data)) > limit {
+			if isFile {
+				return nil, fmt.Errorf("%w: file '%s' exceeds limit of %d bytes", ErrFileTooLarge, part.FileName(), limit)
+			}
+			return nil, fmt.Errorf("%w: field '%s' exceeds limit of %d bytes", ErrRequestTooLarge, field, limit)
+		}
+
+		total += int64(len(data))
+		if limits.MaxTotalSize > 0 && total > limits.MaxTotalSize {
+			return nil, fmt.Errorf("%w: upload exceeds limit of %d bytes", ErrRequestTooLarge, limits.MaxTotalSize)
+		}
+		part.Close()
+
+		if !isFile {
+			form.Values.Add(field, string(data))
+			continue
+		}
+
+		contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+		if !typeAllowed(contentType, limits.AllowedTypes) {
+			return nil, fmt.Errorf("%w: file '%s' is %s", ErrFileTypeNotAllowed, part.FileName(), contentType)
+		}
+
+		form.Files[field] = append(form.Files[field], &UploadedFile{
+			Filename:    part.FileName(),
+			ContentType: contentType,
+			Size:        int64(len(data)),
+			Data:        data,
+		})
+	}
+}
+
+// readPartLimited reads a part, stopping one byte past limit so an
+// oversized part is detected without reading it all. A negative limit
+// reads the whole part.
+func readPartLimited(part io.Reader, limit int64) ([]byte, error) {
+	if limit >= 0 {
+		part = io.LimitReader(part, limit+1)
+	}
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(part); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// typeAllowed checks a media type against an allowlist of types and
+// "type/*" patterns. An empty allowlist allows everything.
+func typeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == contentType {
+			return true
+		}
+		if strings.HasSuffix(a, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+	}
+	return false
+}
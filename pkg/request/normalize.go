@@ -0,0 +1,61 @@
+package request
+
+import (
+	"reflect"
+	"strings"
+)
+
+// normalizers maps normalize tag rules to their string transforms
+var normalizers = map[string]func(string) string{
+	"trim":  strings.TrimSpace,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// Normalize applies the `normalize` struct tags of obj to its string fields
+// in place, e.g. `normalize:"trim,lower"`. Binders call it before Validate.
+func Normalize(obj interface{}) {
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return
+	}
+
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Field(i)
+		fieldType := rt.Field(i)
+
+		if !field.CanSet() {
+			continue
+		}
+
+		// Normalize embedded structs, e.g. shared address fields, in place
+		if fieldType.Anonymous && field.Kind() == reflect.Struct && fieldType.Tag.Get("normalize") == "" {
+			Normalize(field.Addr().Interface())
+			continue
+		}
+
+		if field.Kind() != reflect.String {
+			continue
+		}
+
+		normalizeTag := fieldType.Tag.Get("normalize")
+		if normalizeTag == "" {
+			continue
+		}
+
+		field.SetString(normalizeString(field.String(), normalizeTag))
+	}
+}
+
+// normalizeString applies the comma-separated normalize rules to value
+func normalizeString(value, normalizeTag string) string {
+	for _, rule := range strings.Split(normalizeTag, ",") {
+		if fn, ok := normalizers[strings.TrimSpace(rule)]; ok {
+			value = fn(value)
+		}
+	}
+	return value
+}
@@ -0,0 +1,41 @@
+package request
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+var (
+	patternsMu sync.RWMutex
+
+	// patterns maps names usable in `validate:"pattern=name"` to their
+	// compiled expressions
+	patterns = map[string]*regexp.Regexp{
+		"phone":     regexp.MustCompile(`^\+?[0-9][0-9 ().-]{6,18}[0-9]$`),
+		"zip_us":    regexp.MustCompile(`^[0-9]{5}(-[0-9]{4})?$`),
+		"hex_color": regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`),
+	}
+)
+
+// RegisterPattern compiles pattern and makes it available to the
+// `pattern=name` validation rule, replacing any pattern of the same name
+func RegisterPattern(name, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid regex pattern %s: %w", name, err)
+	}
+
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+	patterns[name] = re
+	return nil
+}
+
+// lookupPattern returns the compiled pattern registered under name
+func lookupPattern(name string) (*regexp.Regexp, bool) {
+	patternsMu.RLock()
+	defer patternsMu.RUnlock()
+	re, ok := patterns[name]
+	return re, ok
+}
@@ -2,10 +2,20 @@
 package request
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -128,14 +138,29 @@ func (r *Request) PostFormValues() url.Values {
 	return r.PostForm
 }
 
-// FileHeader returns the file header for a multipart form file
-func (r *Request) FileHeader(key string) (*multipart.FileHeader, error) {
+// MaxUploadSize is the largest file SaveUploadedFile will accept
+var MaxUploadSize int64 = 32 << 20 // 32MB
+
+// uploadOverhead is the room SaveUploadedFile leaves in the request body,
+// beyond MaxUploadSize, for multipart framing and other form fields
+const uploadOverhead = 1 << 20 // 1MB
+
+// parseMultipart parses the multipart form once per request
+func (r *Request) parseMultipart() error {
 	if !r.parsedMultipart {
 		if err := r.ParseMultipartForm(32 << 20); err != nil { // 32MB
-			return nil, err
+			return err
 		}
 		r.parsedMultipart = true
 	}
+	return nil
+}
+
+// FileHeader returns the file header for a multipart form file
+func (r *Request) FileHeader(key string) (*multipart.FileHeader, error) {
+	if err := r.parseMultipart(); err != nil {
+		return nil, err
+	}
 
 	file, header, err := r.FormFile(key)
 	if err != nil {
@@ -148,11 +173,8 @@ func (r *Request) FileHeader(key string) (*multipart.FileHeader, error) {
 
 // Files returns all file headers for a multipart form
 func (r *Request) Files() map[string][]*multipart.FileHeader {
-	if !r.parsedMultipart {
-		if err := r.ParseMultipartForm(32 << 20); err != nil { // 32MB
-			return nil
-		}
-		r.parsedMultipart = true
+	if err := r.parseMultipart(); err != nil {
+		return nil
 	}
 
 	if r.MultipartForm == nil {
@@ -162,6 +184,87 @@ func (r *Request) Files() map[string][]*multipart.FileHeader {
 	return r.MultipartForm.File
 }
 
+// MultipartReader returns a reader for streaming multipart parts without
+// buffering them. It cannot be used once the form has been parsed.
+func (r *Request) MultipartReader() (*multipart.Reader, error) {
+	if r.parsedMultipart {
+		return nil, fmt.Errorf("multipart form has already been parsed")
+	}
+	return r.Request.MultipartReader()
+}
+
+// EachPart streams each multipart part to fn as it arrives
+func (r *Request) EachPart(fn func(part *multipart.Part) error) error {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = fn(part)
+		part.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// SaveUploadedFile copies the uploaded file for the given form key to dst,
+// creating parent directories as needed
+func (r *Request) SaveUploadedFile(key, dst string) error {
+	// Bound the body before parsing, so an oversized upload is cut off
+	// instead of being read in full and checked afterwards
+	limit := MaxUploadSize + uploadOverhead
+	if !r.parsedMultipart && MaxUploadSize > 0 && r.Request.Body != nil {
+		if r.Request.ContentLength > limit {
+			return fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrRequestTooLarge, r.Request.ContentLength, limit)
+		}
+		r.Request.Body = http.MaxBytesReader(nil, r.Request.Body, limit)
+	}
+
+	if err := r.parseMultipart(); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return fmt.Errorf("%w: limit is %d bytes", ErrRequestTooLarge, limit)
+		}
+		return err
+	}
+
+	file, header, err := r.FormFile(key)
+	if err != nil {
+		if err == http.ErrMissingFile {
+			return fmt.Errorf("form file '%s' not found", key)
+		}
+		return err
+	}
+	defer file.Close()
+
+	if MaxUploadSize > 0 && header.Size > MaxUploadSize {
+		return fmt.Errorf("file '%s' exceeds maximum upload size of %d bytes", header.Filename, MaxUploadSize)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, file)
+	return err
+}
+
 // HeaderValue returns a header value
 func (r *Request) HeaderValue(key string) string {
 	return r.Header.Get(key)
@@ -261,6 +364,17 @@ func (r *Request) IsAjax() bool {
 	return r.HeaderValue("X-Requested-With") == "XMLHttpRequest"
 }
 
+// IsWebSocket checks if the request is a WebSocket upgrade
+func (r *Request) IsWebSocket() bool {
+	return contains(r.HeaderValue("Connection"), "upgrade") &&
+		strings.EqualFold(r.HeaderValue("Upgrade"), "websocket")
+}
+
+// IsSSE checks if the request asks for a Server-Sent Events stream
+func (r *Request) IsSSE() bool {
+	return contains(r.Accept(), "text/event-stream")
+}
+
 // IsSecure checks if the request is HTTPS
 func (r *Request) IsSecure() bool {
 	return r.TLS != nil || r.HeaderValue("X-Forwarded-Proto") == "https"
@@ -294,6 +408,41 @@ func (r *Request) ContentType() string {
 	return GetContentType(r.Request)
 }
 
+// IsJSON checks if the request body is JSON
+func (r *Request) IsJSON() bool {
+	return IsJSON(r.Request)
+}
+
+// IsXML checks if the request body is XML
+func (r *Request) IsXML() bool {
+	return IsXML(r.Request)
+}
+
+// IsForm checks if the request body is URL-encoded or multipart form data
+func (r *Request) IsForm() bool {
+	return IsForm(r.Request)
+}
+
+// IsYAML checks if the request body is YAML
+func (r *Request) IsYAML() bool {
+	return IsYAML(r.Request)
+}
+
+// Is checks if the request content type matches mediaType, ignoring
+// parameters such as charset. A "type/*" pattern matches any subtype.
+func (r *Request) Is(mediaType string) bool {
+	contentType, _, err := mime.ParseMediaType(r.ContentType())
+	if err != nil {
+		return false
+	}
+
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if strings.HasSuffix(mediaType, "/*") {
+		return strings.HasPrefix(contentType, strings.TrimSuffix(mediaType, "*"))
+	}
+	return contentType == mediaType
+}
+
 // Accept returns the Accept header
 func (r *Request) Accept() string {
 	return r.HeaderValue("Accept")
@@ -468,6 +617,90 @@ func (r *Request) AuthToken() string {
 	return auth.Token
 }
 
+// vendorVersionPattern matches versioned vendor media types such as
+// application/vnd.myapp.v2+json
+var vendorVersionPattern = regexp.MustCompile(`^[a-z]+/vnd\.[^+;]+\.(v[0-9]+(?:\.[0-9]+)*)(?:\+[a-z0-9.-]+)?$`)
+
+// AcceptVersion returns the API version requested through the Accept
+// header, either as a vendor media type ("application/vnd.myapp.v2+json")
+// or a version parameter ("application/json; version=2"), or defaultV if
+// none is given
+func (r *Request) AcceptVersion(defaultV string) string {
+	for _, part := range strings.Split(r.Accept(), ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+		if m := vendorVersionPattern.FindStringSubmatch(mediaType); m != nil {
+			return m[1]
+		}
+
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(key, "version") && value != "" {
+				if !strings.HasPrefix(value, "v") {
+					value = "v" + value
+				}
+				return value
+			}
+		}
+	}
+	return defaultV
+}
+
+// PreferredLanguage returns the client's most preferred language tag from
+// Accept-Language. When available tags are given it returns the best of
+// those instead, matching either the full tag or its primary language
+// ("fr-CH" accepts "fr"), or "" if none is acceptable.
+func (r *Request) PreferredLanguage(available ...string) string {
+	type langQ struct {
+		tag string
+		q   float64
+	}
+
+	var langs []langQ
+	for _, part := range strings.Split(r.AcceptLanguage(), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			langs = append(langs, langQ{tag: strings.ToLower(tag), q: q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	if len(available) == 0 {
+		if len(langs) == 0 || langs[0].tag == "*" {
+			return ""
+		}
+		return langs[0].tag
+	}
+
+	for _, lang := range langs {
+		if lang.tag == "*" {
+			return available[0]
+		}
+		primary, _, _ := strings.Cut(lang.tag, "-")
+		for _, tag := range available {
+			if strings.EqualFold(tag, lang.tag) {
+				return tag
+			}
+		}
+		for _, tag := range available {
+			if strings.EqualFold(tag, primary) {
+				return tag
+			}
+		}
+	}
+	return ""
+}
+
 // IsAuthType checks if the request uses a specific auth type
 func (r *Request) IsAuthType(authType string) bool {
 	return r.AuthType() == authType
@@ -512,6 +745,8 @@ func (r *Request) SmartBind(obj interface{}) error {
 		return BindXML(r.Request, obj)
 	case IsYAML(r.Request):
 		return BindYAML(r.Request, obj)
+	case IsTOML(r.Request):
+		return BindTOML(r.Request, obj)
 	case IsForm(r.Request):
 		return BindForm(r.Request, obj)
 	default:
@@ -520,6 +755,65 @@ func (r *Request) SmartBind(obj interface{}) error {
 	}
 }
 
+// PropagatedHeaders lists the incoming headers copied onto outbound
+// requests by OutgoingHeaders
+var PropagatedHeaders = []string{"X-Request-ID", "Traceparent", "Tracestate"}
+
+// OutgoingHeaders returns the request ID and trace headers of the current
+// request, ready to copy onto outbound requests to other services
+func (r *Request) OutgoingHeaders() http.Header {
+	headers := make(http.Header)
+	for _, key := range PropagatedHeaders {
+		if value := r.HeaderValue(key); value != "" {
+			headers.Set(key, value)
+		}
+	}
+	return headers
+}
+
+// NewOutgoingRequest creates an outbound request bound to the current
+// request's context and carrying its OutgoingHeaders
+func (r *Request) NewOutgoingRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(r.Context(), method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range r.OutgoingHeaders() {
+		req.Header[key] = values
+	}
+	return req, nil
+}
+
+// FingerprintHeaders lists the headers that distinguish otherwise equal
+// requests in Fingerprint. Volatile headers such as Date or X-Request-ID
+// should not be listed.
+var FingerprintHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language"}
+
+// Fingerprint returns a stable key for the request, hashed from its
+// method, cleaned path, sorted query and FingerprintHeaders, for use in
+// caching and deduplication
+func (r *Request) Fingerprint() string {
+	query := r.URL.Query()
+	for _, values := range query {
+		sort.Strings(values)
+	}
+
+	cleaned := path.Clean("/" + r.URL.Path)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", strings.ToUpper(r.Method), cleaned, query.Encode())
+	for _, key := range FingerprintHeaders {
+		fmt.Fprintf(h, "%s:%s\n", strings.ToLower(key), r.HeaderValue(key))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// BindWith binds the request using the given binder, ignoring content type
+func (r *Request) BindWith(obj interface{}, binder Binder) error {
+	return BindWith(r.Request, obj, binder)
+}
+
 // contains checks if a string contains a substring (case-insensitive)
 func contains(s, substr string) bool {
 	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
@@ -1,11 +1,20 @@
 package request
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/aliwert/go-wolf/pkg/codec"
 )
 
 // Test structs
@@ -465,3 +474,1181 @@ func TestAuthHelpers(t *testing.T) {
 		t.Error("expected IsAuthType('basic') to return false")
 	}
 }
+
+type spyCodec struct {
+	codec.Std
+	decodes int
+}
+
+func (s *spyCodec) NewDecoder(r io.Reader) codec.Decoder {
+	s.decodes++
+	return s.Std.NewDecoder(r)
+}
+
+func TestJSONCodec(t *testing.T) {
+	spy := &spyCodec{}
+	codec.SetJSON(spy)
+	defer codec.SetJSON(nil)
+
+	body := `{"name":"John","email":"john@example.com","age":30,"username":"john123"}`
+	req := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	var user User
+	if err := BindJSON(req, &user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if spy.decodes != 1 {
+		t.Errorf("expected 1 decoder use, got %d", spy.decodes)
+	}
+	if user.Name != "John" {
+		t.Errorf("expected name John, got %s", user.Name)
+	}
+}
+
+func TestSetJSONUseNumber(t *testing.T) {
+	type Event struct {
+		Payload map[string]interface{} `json:"payload"`
+		Raw     interface{}            `json:"raw"`
+	}
+	body := `{"payload":{"id":9007199254740993},"raw":9223372036854775807}`
+
+	req := httptest.NewRequest("POST", "/events", strings.NewReader(body))
+	var lossy Event
+	if err := BindJSON(req, &lossy); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := lossy.Payload["id"].(float64); !ok {
+		t.Errorf("expected float64 without UseNumber, got %T", lossy.Payload["id"])
+	}
+
+	SetJSONUseNumber(true)
+	defer SetJSONUseNumber(false)
+
+	req = httptest.NewRequest("POST", "/events", strings.NewReader(body))
+	var event Event
+	if err := BindJSON(req, &event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	id, ok := event.Payload["id"].(json.Number)
+	if !ok || id.String() != "9007199254740993" {
+		t.Errorf("expected json.Number 9007199254740993, got %T %v", event.Payload["id"], event.Payload["id"])
+	}
+	raw, ok := event.Raw.(json.Number)
+	if !ok {
+		t.Fatalf("expected json.Number, got %T", event.Raw)
+	}
+	if n, err := raw.Int64(); err != nil || n != 9223372036854775807 {
+		t.Errorf("expected 9223372036854775807, got %d (%v)", n, err)
+	}
+}
+
+func TestSmartBindTolerant(t *testing.T) {
+	body := "name=John&email=john%40example.com&age=30&username=john123"
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("POST", "/users", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	var strict User
+	if err := SmartBind(newRequest(), &strict); err == nil {
+		t.Fatal("expected SmartBind to fail on a form body labeled as JSON")
+	}
+
+	var user User
+	if err := SmartBindTolerant(newRequest(), &user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.Name != "John" || user.Email != "john@example.com" || user.Age != 30 {
+		t.Errorf("expected form body to bind, got %+v", user)
+	}
+
+	// Validation failures are reported, not retried
+	req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"J"}`))
+	req.Header.Set("Content-Type", "application/json")
+	var errs ValidationErrors
+	if err := SmartBindTolerant(req, &User{}); !errors.As(err, &errs) {
+		t.Errorf("expected ValidationErrors, got %v", err)
+	}
+
+	// A body that matches no binder keeps the original decode error
+	req = httptest.NewRequest("POST", "/users", strings.NewReader("not json"))
+	req.Header.Set("Content-Type", "application/json")
+	var bindErr *BindError
+	if err := SmartBindTolerant(req, &User{}); !errors.As(err, &bindErr) || bindErr.Source != "json" {
+		t.Errorf("expected json BindError, got %v", err)
+	}
+
+	// The buffered body is bounded by MaxJSONSize, declared or not
+	defer func(limit int64) { MaxJSONSize = limit }(MaxJSONSize)
+	MaxJSONSize = 16
+
+	if err := SmartBindTolerant(newRequest(), &User{}); !errors.Is(err, ErrRequestTooLarge) {
+		t.Errorf("expected ErrRequestTooLarge, got %v", err)
+	}
+
+	req = newRequest()
+	req.ContentLength = -1
+	if err := SmartBindTolerant(req, &User{}); !errors.Is(err, ErrRequestTooLarge) {
+		t.Errorf("expected ErrRequestTooLarge for unknown length, got %v", err)
+	}
+}
+
+func TestSaveUploadedFile(t *testing.T) {
+	newUpload := func(field, content string) *http.Request {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		part, _ := mw.CreateFormFile(field, "hello.txt")
+		part.Write([]byte(content))
+		mw.Close()
+
+		req := httptest.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return req
+	}
+
+	t.Run("saves file", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "nested", "dir", "hello.txt")
+		r := New(newUpload("file", "hello world"))
+
+		if err := r.SaveUploadedFile("file", dst); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		data, err := os.ReadFile(dst)
+		if err != nil {
+			t.Fatalf("failed to read saved file: %v", err)
+		}
+		if string(data) != "hello world" {
+			t.Errorf("expected 'hello world', got %q", data)
+		}
+	})
+
+	t.Run("missing field", func(t *testing.T) {
+		r := New(newUpload("other", "data"))
+
+		err := r.SaveUploadedFile("file", filepath.Join(t.TempDir(), "x.txt"))
+		if err == nil || !strings.Contains(err.Error(), "'file' not found") {
+			t.Errorf("expected missing field error, got %v", err)
+		}
+	})
+
+	t.Run("exceeds size limit", func(t *testing.T) {
+		old := MaxUploadSize
+		MaxUploadSize = 4
+		defer func() { MaxUploadSize = old }()
+
+		r := New(newUpload("file", "too large"))
+
+		err := r.SaveUploadedFile("file", filepath.Join(t.TempDir(), "x.txt"))
+		if err == nil || !strings.Contains(err.Error(), "exceeds maximum upload size") {
+			t.Errorf("expected size limit error, got %v", err)
+		}
+	})
+
+	t.Run("oversized body is not read in full", func(t *testing.T) {
+		old := MaxUploadSize
+		MaxUploadSize = 4
+		defer func() { MaxUploadSize = old }()
+
+		req := newUpload("file", strings.Repeat("x", 4<<20))
+		req.ContentLength = -1
+		body := &countingReader{r: req.Body}
+		req.Body = io.NopCloser(body)
+
+		err := New(req).SaveUploadedFile("file", filepath.Join(t.TempDir(), "x.txt"))
+		if !errors.Is(err, ErrRequestTooLarge) {
+			t.Errorf("expected ErrRequestTooLarge, got %v", err)
+		}
+		if limit := MaxUploadSize + uploadOverhead; int64(body.n) > limit+1 {
+			t.Errorf("expected at most %d bytes to be read, read %d", limit+1, body.n)
+		}
+	})
+}
+
+func TestEachPart(t *testing.T) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mw.WriteField("title", "report")
+	part, _ := mw.CreateFormFile("file", "data.csv")
+	part.Write([]byte("a,b,c"))
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	r := New(req)
+
+	var names, contents []string
+	err := r.EachPart(func(p *multipart.Part) error {
+		data, err := io.ReadAll(p)
+		if err != nil {
+			return err
+		}
+		names = append(names, p.FormName())
+		contents = append(contents, string(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(names) != 2 || names[0] != "title" || names[1] != "file" {
+		t.Errorf("expected parts [title file], got %v", names)
+	}
+	if len(contents) != 2 || contents[0] != "report" || contents[1] != "a,b,c" {
+		t.Errorf("expected contents [report a,b,c], got %v", contents)
+	}
+
+	if _, err := New(httptest.NewRequest("POST", "/upload", nil)).MultipartReader(); err == nil {
+		t.Error("expected error for non-multipart request")
+	}
+}
+
+func TestBindJSONMaxSize(t *testing.T) {
+	old := MaxJSONSize
+	MaxJSONSize = 32
+	defer func() { MaxJSONSize = old }()
+
+	body := `{"name":"John","email":"john@example.com","username":"john123"}`
+
+	t.Run("declared length over limit", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		var user User
+		err := BindJSON(req, &user)
+		if !errors.Is(err, ErrRequestTooLarge) {
+			t.Errorf("expected ErrRequestTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("chunked body over limit", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.ContentLength = -1
+		req.TransferEncoding = []string{"chunked"}
+
+		var user User
+		err := BindJSON(req, &user)
+		if !errors.Is(err, ErrRequestTooLarge) {
+			t.Errorf("expected ErrRequestTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("within limit", func(t *testing.T) {
+		MaxJSONSize = 1024
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		var user User
+		if err := BindJSON(req, &user); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestTransportDetection(t *testing.T) {
+	tests := []struct {
+		name      string
+		headers   map[string]string
+		websocket bool
+		sse       bool
+		ajax      bool
+	}{
+		{
+			name:      "websocket upgrade",
+			headers:   map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "websocket"},
+			websocket: true,
+		},
+		{
+			name:    "upgrade without connection header",
+			headers: map[string]string{"Upgrade": "websocket"},
+		},
+		{
+			name:    "event stream",
+			headers: map[string]string{"Accept": "text/event-stream"},
+			sse:     true,
+		},
+		{
+			name:    "ajax",
+			headers: map[string]string{"X-Requested-With": "XMLHttpRequest", "Accept": "application/json"},
+			ajax:    true,
+		},
+		{
+			name: "plain request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			r := New(req)
+
+			if r.IsWebSocket() != tt.websocket {
+				t.Errorf("expected IsWebSocket %v, got %v", tt.websocket, r.IsWebSocket())
+			}
+			if r.IsSSE() != tt.sse {
+				t.Errorf("expected IsSSE %v, got %v", tt.sse, r.IsSSE())
+			}
+			if r.IsAjax() != tt.ajax {
+				t.Errorf("expected IsAjax %v, got %v", tt.ajax, r.IsAjax())
+			}
+		})
+	}
+}
+
+func TestBindErrorSeparation(t *testing.T) {
+	t.Run("malformed JSON", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(`{"name":`))
+		req.Header.Set("Content-Type", "application/json")
+
+		var user User
+		err := BindJSON(req, &user)
+
+		var bindErr *BindError
+		if !errors.As(err, &bindErr) {
+			t.Fatalf("expected *BindError, got %T: %v", err, err)
+		}
+		if bindErr.Source != "json" {
+			t.Errorf("expected source json, got %s", bindErr.Source)
+		}
+	})
+
+	t.Run("invalid query value", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test?age=abc", nil)
+
+		var user User
+		err := BindQuery(req, &user)
+
+		var bindErr *BindError
+		if !errors.As(err, &bindErr) || bindErr.Source != "query" {
+			t.Errorf("expected query *BindError, got %T: %v", err, err)
+		}
+	})
+
+	t.Run("valid JSON failing validation", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(`{"name":"J"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		var user User
+		err := BindJSON(req, &user)
+
+		var bindErr *BindError
+		if errors.As(err, &bindErr) {
+			t.Fatalf("expected validation error, got *BindError: %v", err)
+		}
+		if _, ok := err.(ValidationErrors); !ok {
+			t.Errorf("expected ValidationErrors, got %T: %v", err, err)
+		}
+	})
+}
+
+func TestBindTOML(t *testing.T) {
+	type Config struct {
+		Name  string `toml:"name" validate:"required"`
+		Port  int    `toml:"port"`
+		Debug bool   `toml:"debug"`
+	}
+
+	body := "name = \"wolf\"\nport = 8080\ndebug = true\n"
+	req := httptest.NewRequest("POST", "/config", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/toml")
+
+	if !IsTOML(req) {
+		t.Fatal("IsTOML() = false, want true")
+	}
+
+	var cfg Config
+	if err := SmartBind(req, &cfg); err != nil {
+		t.Fatalf("SmartBind() error = %v", err)
+	}
+	if cfg.Name != "wolf" || cfg.Port != 8080 || !cfg.Debug {
+		t.Errorf("unexpected result: %+v", cfg)
+	}
+
+	req = httptest.NewRequest("POST", "/config", strings.NewReader("name = "))
+	req.Header.Set("Content-Type", "application/toml")
+
+	var bindErr *BindError
+	if err := BindTOML(req, &cfg); !errors.As(err, &bindErr) || bindErr.Source != "toml" {
+		t.Errorf("expected toml BindError, got %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/config", strings.NewReader("port = 1\n"))
+	req.Header.Set("Content-Type", "application/toml")
+
+	var empty Config
+	if err := BindTOML(req, &empty); err == nil || errors.As(err, &bindErr) {
+		t.Errorf("expected validation error, got %v", err)
+	}
+}
+
+func TestEncodingValidation(t *testing.T) {
+	type Token struct {
+		Std  string `validate:"base64"`
+		URL  string `validate:"base64url"`
+		Hash string `validate:"hex"`
+	}
+
+	valid := Token{Std: "aGVsbG8=", URL: "aGVsbG8", Hash: "deadbeef"}
+	if err := Validate(&valid); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if err := Validate(&Token{}); err != nil {
+		t.Errorf("expected empty fields to be skipped, got %v", err)
+	}
+
+	invalid := Token{Std: "aGVsbG8", URL: "+/+/", Hash: "xyz"}
+	err := Validate(&invalid)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %T", err)
+	}
+	if len(errs) != 3 {
+		t.Errorf("expected 3 errors, got %d: %v", len(errs), errs)
+	}
+}
+
+func TestBindWith(t *testing.T) {
+	type Filter struct {
+		Page int    `query:"page" json:"page"`
+		Sort string `query:"sort" json:"sort"`
+	}
+
+	req := httptest.NewRequest("GET", "/items?page=2&sort=name", strings.NewReader(`{"page":9}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	var filter Filter
+	if err := New(req).BindWith(&filter, QueryBinder); err != nil {
+		t.Fatalf("BindWith() error = %v", err)
+	}
+	if filter.Page != 2 || filter.Sort != "name" {
+		t.Errorf("expected query values, got %+v", filter)
+	}
+
+	var custom Filter
+	binder := BinderFunc(func(r *http.Request, obj interface{}) error {
+		obj.(*Filter).Sort = "custom"
+		return nil
+	})
+	if err := BindWith(req, &custom, binder); err != nil || custom.Sort != "custom" {
+		t.Errorf("expected custom binder to run, got %+v, %v", custom, err)
+	}
+
+	if err := BindWith(req, &custom, nil); err == nil {
+		t.Error("expected error for nil binder")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	type Signup struct {
+		Email string `json:"email" form:"email" normalize:"trim,lower" validate:"required,email"`
+		Code  string `json:"code" form:"code" normalize:"upper"`
+		Note  string `json:"note" form:"note"`
+	}
+
+	t.Run("form", func(t *testing.T) {
+		form := url.Values{}
+		form.Set("email", "  John@Example.COM ")
+		form.Set("code", "ab12")
+		form.Set("note", " keep ")
+
+		req := httptest.NewRequest("POST", "/signup", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		var s Signup
+		if err := BindForm(req, &s); err != nil {
+			t.Fatalf("BindForm() error = %v", err)
+		}
+		if s.Email != "john@example.com" {
+			t.Errorf("expected normalized email, got %q", s.Email)
+		}
+		if s.Code != "AB12" {
+			t.Errorf("expected upper-cased code, got %q", s.Code)
+		}
+		if s.Note != " keep " {
+			t.Errorf("expected untagged field untouched, got %q", s.Note)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/signup", strings.NewReader(`{"email":"  John@Example.COM "}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		var s Signup
+		if err := BindJSON(req, &s); err != nil {
+			t.Fatalf("BindJSON() error = %v", err)
+		}
+		if s.Email != "john@example.com" {
+			t.Errorf("expected normalized email, got %q", s.Email)
+		}
+	})
+
+	t.Run("embedded", func(t *testing.T) {
+		type Invite struct {
+			Signup
+			Team string `normalize:"trim"`
+		}
+
+		invite := &Invite{Signup: Signup{Email: " A@B.io ", Code: "x1"}, Team: " core "}
+		Normalize(invite)
+		if invite.Email != "a@b.io" || invite.Code != "X1" || invite.Team != "core" {
+			t.Errorf("expected embedded fields normalized, got %+v", invite)
+		}
+	})
+}
+
+func TestBindJSONMaxDepth(t *testing.T) {
+	SetMaxJSONDepth(3)
+	defer SetMaxJSONDepth(0)
+
+	type Payload struct {
+		A interface{} `json:"a"`
+	}
+
+	bind := func(body string) (Payload, error) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		var p Payload
+		err := BindJSON(req, &p)
+		return p, err
+	}
+
+	p, err := bind(`{"a":{"b":[1,2]}}`)
+	if err != nil {
+		t.Fatalf("expected payload at the limit to bind, got %v", err)
+	}
+	if p.A == nil {
+		t.Error("expected body to be decoded after the depth check")
+	}
+
+	_, err = bind(`{"a":{"b":[{"c":1}]}}`)
+	if !errors.Is(err, ErrJSONTooDeep) {
+		t.Fatalf("expected ErrJSONTooDeep, got %v", err)
+	}
+
+	var bindErr *BindError
+	if !errors.As(err, &bindErr) || bindErr.Source != "json" {
+		t.Errorf("expected json BindError, got %v", err)
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		header    string
+		available []string
+		expected  string
+	}{
+		{"fr-CH, fr;q=0.9, en;q=0.8", nil, "fr-ch"},
+		{"en;q=0.5, fr;q=0.9", nil, "fr"},
+		{"", nil, ""},
+		{"fr-CH, en;q=0.8", []string{"en", "fr"}, "fr"},
+		{"de, en;q=0.5", []string{"en", "fr"}, "en"},
+		{"de", []string{"en", "fr"}, ""},
+		{"de, *;q=0.1", []string{"en", "fr"}, "en"},
+		{"fr;q=0", []string{"fr", "en"}, ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			req.Header.Set("Accept-Language", tt.header)
+		}
+
+		if got := New(req).PreferredLanguage(tt.available...); got != tt.expected {
+			t.Errorf("PreferredLanguage(%q, %v) = %q, expected %q", tt.header, tt.available, got, tt.expected)
+		}
+	}
+}
+
+func TestBindAllErrors(t *testing.T) {
+	type Search struct {
+		ID    int    `path:"id"`
+		Page  int    `query:"page"`
+		Limit int    `form:"limit"`
+		Token string `header:"x-token"`
+	}
+
+	form := url.Values{}
+	form.Set("limit", "lots")
+	req := httptest.NewRequest("POST", "/items/7?page=first", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Token", "abc")
+
+	var s Search
+	err := BindAll(req, map[string]string{"id": "7"}, &s)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var errs BindErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected BindErrors, got %T: %v", err, err)
+	}
+
+	sources := make(map[string]bool)
+	for _, e := range errs {
+		sources[e.Source] = true
+	}
+	if len(errs) != 2 || !sources["query"] || !sources["form"] {
+		t.Errorf("expected query and form errors, got %v", errs)
+	}
+	if !strings.Contains(err.Error(), "query:") || !strings.Contains(err.Error(), "form:") {
+		t.Errorf("expected message to name both sources, got %q", err.Error())
+	}
+	for _, e := range errs {
+		if e.Source == "form" && !strings.Contains(e.Error(), "Limit") {
+			t.Errorf("expected the form error to name Limit, not a query field, got %q", e.Error())
+		}
+	}
+
+	// Valid sources still bind
+	if s.ID != 7 || s.Token != "abc" {
+		t.Errorf("expected path and header to bind, got %+v", s)
+	}
+
+	req = httptest.NewRequest("GET", "/items/7?page=2", nil)
+	var ok Search
+	if err := BindAll(req, map[string]string{"id": "7"}, &ok); err != nil {
+		t.Fatalf("BindAll() error = %v", err)
+	}
+	if ok.ID != 7 || ok.Page != 2 {
+		t.Errorf("unexpected result: %+v", ok)
+	}
+}
+
+func TestOutgoingRequest(t *testing.T) {
+	incoming := httptest.NewRequest("GET", "/orders", nil)
+	incoming.Header.Set("X-Request-ID", "req-123")
+	incoming.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	incoming.Header.Set("Authorization", "Bearer secret")
+
+	r := New(incoming)
+
+	headers := r.OutgoingHeaders()
+	if headers.Get("X-Request-ID") != "req-123" {
+		t.Errorf("expected request ID to propagate, got %q", headers.Get("X-Request-ID"))
+	}
+	if headers.Get("Authorization") != "" {
+		t.Error("expected Authorization not to propagate")
+	}
+
+	out, err := r.NewOutgoingRequest("POST", "http://inventory.local/reserve", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("NewOutgoingRequest() error = %v", err)
+	}
+	if out.Header.Get("X-Request-ID") != "req-123" {
+		t.Errorf("expected outbound request ID req-123, got %q", out.Header.Get("X-Request-ID"))
+	}
+	if out.Header.Get("Traceparent") == "" {
+		t.Error("expected traceparent on outbound request")
+	}
+	if out.Context() != incoming.Context() {
+		t.Error("expected outbound request to share the incoming context")
+	}
+}
+
+func TestBindJSONMergePatch(t *testing.T) {
+	type Address struct {
+		City string `json:"city"`
+		Zip  string `json:"zip,omitempty"`
+	}
+	type Profile struct {
+		Name    string   `json:"name" validate:"required"`
+		Bio     string   `json:"bio,omitempty"`
+		Age     int      `json:"age"`
+		Address *Address `json:"address,omitempty"`
+	}
+
+	patch := func(original *Profile, body string) (Profile, error) {
+		req := httptest.NewRequest("PATCH", "/profile", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		var result Profile
+		err := BindJSONMergePatch(req, original, &result)
+		return result, err
+	}
+
+	original := &Profile{Name: "Ada", Bio: "mathematician", Age: 36, Address: &Address{City: "London", Zip: "W1"}}
+
+	result, err := patch(original, `{"age": 37}`)
+	if err != nil {
+		t.Fatalf("BindJSONMergePatch() error = %v", err)
+	}
+	if result.Age != 37 || result.Name != "Ada" || result.Bio != "mathematician" {
+		t.Errorf("expected only age to change, got %+v", result)
+	}
+
+	result, err = patch(original, `{"bio": null, "address": {"zip": null}}`)
+	if err != nil {
+		t.Fatalf("BindJSONMergePatch() error = %v", err)
+	}
+	if result.Bio != "" {
+		t.Errorf("expected bio removed, got %q", result.Bio)
+	}
+	if result.Address == nil || result.Address.City != "London" || result.Address.Zip != "" {
+		t.Errorf("expected nested zip removed and city kept, got %+v", result.Address)
+	}
+
+	if _, err := patch(original, `{"name": null}`); err == nil {
+		t.Error("expected validation error after removing required name")
+	}
+
+	// Patching in place
+	inPlace := *original
+	req := httptest.NewRequest("PATCH", "/profile", strings.NewReader(`{"bio": null}`))
+	if err := BindJSONMergePatch(req, &inPlace, &inPlace); err != nil {
+		t.Fatalf("BindJSONMergePatch() error = %v", err)
+	}
+	if inPlace.Bio != "" || inPlace.Name != "Ada" {
+		t.Errorf("unexpected in-place result: %+v", inPlace)
+	}
+
+	// Large integers the patch doesn't touch keep their exact value
+	type Account struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+	account := &Account{ID: 9007199254740993, Name: "ops"}
+	req = httptest.NewRequest("PATCH", "/account", strings.NewReader(`{"name": "dev"}`))
+	var patched Account
+	if err := BindJSONMergePatch(req, account, &patched); err != nil {
+		t.Fatalf("BindJSONMergePatch() error = %v", err)
+	}
+	if patched.ID != 9007199254740993 || patched.Name != "dev" {
+		t.Errorf("expected id kept exactly and name patched, got %+v", patched)
+	}
+}
+
+func TestBindQueryEmbedded(t *testing.T) {
+	type Pagination struct {
+		Page    int `query:"page" validate:"min=1"`
+		PerPage int `query:"per_page" validate:"max=100"`
+	}
+	type Filter struct {
+		Pagination
+		Status string `query:"status" validate:"oneof=open closed"`
+	}
+
+	req := httptest.NewRequest("GET", "/issues?page=2&per_page=50&status=open", nil)
+	var f Filter
+	if err := BindQuery(req, &f); err != nil {
+		t.Fatalf("BindQuery() error = %v", err)
+	}
+	if f.Page != 2 || f.PerPage != 50 || f.Status != "open" {
+		t.Errorf("unexpected result: %+v", f)
+	}
+
+	req = httptest.NewRequest("GET", "/issues?page=0&status=archived", nil)
+	var bad Filter
+	err := BindQuery(req, &bad)
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %T: %v", err, err)
+	}
+
+	tags := make(map[string]string)
+	for _, e := range errs {
+		tags[e.Field] = e.Tag
+	}
+	if tags["Status"] != "oneof" || tags["Page"] != "min" {
+		t.Errorf("expected oneof on Status and min on Page, got %v", errs)
+	}
+}
+
+func TestAcceptVersion(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"application/vnd.myapp.v2+json", "v2"},
+		{"application/vnd.myapp.v3", "v3"},
+		{"text/html, application/vnd.acme.billing.v1.2+json;q=0.9", "v1.2"},
+		{"application/json; version=4", "v4"},
+		{"application/json", "v1"},
+		{"", "v1"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+
+		if got := New(req).AcceptVersion("v1"); got != tt.expected {
+			t.Errorf("AcceptVersion(%q) = %q, expected %q", tt.accept, got, tt.expected)
+		}
+	}
+}
+
+func TestRequestContentTypeChecks(t *testing.T) {
+	tests := []struct {
+		contentType string
+		json        bool
+		xml         bool
+		form        bool
+		yaml        bool
+	}{
+		{"application/json; charset=utf-8", true, false, false, false},
+		{"text/xml", false, true, false, false},
+		{"application/xml", false, true, false, false},
+		{"application/x-www-form-urlencoded", false, false, true, false},
+		{"multipart/form-data; boundary=abc", false, false, true, false},
+		{"application/yaml", false, false, false, true},
+		{"text/plain", false, false, false, false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Header.Set("Content-Type", tt.contentType)
+		r := New(req)
+
+		if r.IsJSON() != tt.json {
+			t.Errorf("IsJSON() for %q = %v, expected %v", tt.contentType, r.IsJSON(), tt.json)
+		}
+		if r.IsXML() != tt.xml {
+			t.Errorf("IsXML() for %q = %v, expected %v", tt.contentType, r.IsXML(), tt.xml)
+		}
+		if r.IsForm() != tt.form {
+			t.Errorf("IsForm() for %q = %v, expected %v", tt.contentType, r.IsForm(), tt.form)
+		}
+		if r.IsYAML() != tt.yaml {
+			t.Errorf("IsYAML() for %q = %v, expected %v", tt.contentType, r.IsYAML(), tt.yaml)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("Content-Type", "Application/JSON; charset=utf-8")
+	r := New(req)
+
+	if !r.Is("application/json") {
+		t.Error("Expected Is to match ignoring case and parameters")
+	}
+	if !r.Is("application/*") {
+		t.Error("Expected Is to match a subtype wildcard")
+	}
+	if r.Is("application/xml") || r.Is("text/*") {
+		t.Error("Expected Is not to match a different media type")
+	}
+}
+
+func TestBindJSONEmptyBody(t *testing.T) {
+	type Filter struct {
+		Query string `json:"query"`
+	}
+	type Login struct {
+		Username string `json:"username" validate:"required"`
+	}
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(""))
+	var filter Filter
+	if err := BindJSON(req, &filter); err != nil {
+		t.Errorf("Expected empty body to bind no data, got %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader(""))
+	var login Login
+	err := BindJSON(req, &login)
+	var validationErrs ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		t.Errorf("Expected validation error for required field, got %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader(""))
+	err = BindJSONRequired(req, &filter)
+	if !errors.Is(err, ErrEmptyBody) {
+		t.Errorf("Expected ErrEmptyBody, got %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader(`{"query":"wolf"}`))
+	if err := BindJSONRequired(req, &filter); err != nil || filter.Query != "wolf" {
+		t.Errorf("Expected BindJSONRequired to bind body, got %v (%+v)", err, filter)
+	}
+}
+
+func TestLuhnValidation(t *testing.T) {
+	type Payment struct {
+		Card string `validate:"luhn"`
+	}
+
+	for _, card := range []string{"4111111111111111", "4111 1111 1111 1111", "4111-1111-1111-1111"} {
+		if err := Validate(&Payment{Card: card}); err != nil {
+			t.Errorf("expected %q to be valid, got %v", card, err)
+		}
+	}
+
+	err := Validate(&Payment{Card: "4111111111111112"})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Tag != "luhn" {
+		t.Errorf("expected luhn validation error, got %v", err)
+	}
+}
+
+func TestSemVerValidation(t *testing.T) {
+	type Release struct {
+		Version string `validate:"semver"`
+	}
+
+	for _, version := range []string{"1.2.3", "1.0.0-rc.1+build5"} {
+		if err := Validate(&Release{Version: version}); err != nil {
+			t.Errorf("expected %q to be valid, got %v", version, err)
+		}
+	}
+
+	for _, version := range []string{"1.2", "v1.2.3"} {
+		err := Validate(&Release{Version: version})
+		var errs ValidationErrors
+		if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Tag != "semver" {
+			t.Errorf("expected semver validation error for %q, got %v", version, err)
+		}
+	}
+}
+
+func TestISOCodeValidation(t *testing.T) {
+	type Address struct {
+		Country  string `validate:"country"`
+		Language string `validate:"language"`
+	}
+
+	addr := &Address{Country: "us", Language: "EN"}
+	if err := Validate(addr); err != nil {
+		t.Fatalf("expected valid codes, got %v", err)
+	}
+	if addr.Country != "US" || addr.Language != "en" {
+		t.Errorf("expected codes normalized to US/en, got %s/%s", addr.Country, addr.Language)
+	}
+
+	err := Validate(&Address{Country: "XX", Language: "zz"})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("expected two validation errors, got %v", err)
+	}
+	if errs[0].Tag != "country" || errs[1].Tag != "language" {
+		t.Errorf("expected country and language tags, got %s and %s", errs[0].Tag, errs[1].Tag)
+	}
+
+	// Embedded structs are normalized in place too
+	type Order struct {
+		Address
+		Ref string `validate:"required"`
+	}
+	order := &Order{Address: Address{Country: "de", Language: "DE"}, Ref: "A1"}
+	if err := Validate(order); err != nil {
+		t.Fatalf("expected valid embedded codes, got %v", err)
+	}
+	if order.Country != "DE" || order.Language != "de" {
+		t.Errorf("expected embedded codes normalized to DE/de, got %s/%s", order.Country, order.Language)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := httptest.NewRequest("GET", "/search?q=wolf&page=2&tag=b&tag=a", nil)
+	a.Header.Set("Accept", "application/json")
+	a.Header.Set("Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+
+	b := httptest.NewRequest("GET", "/search/?tag=a&page=2&q=wolf&tag=b", nil)
+	b.Header.Set("Accept", "application/json")
+	b.Header.Set("Date", "Tue, 03 Jan 2006 10:00:00 GMT")
+
+	if New(a).Fingerprint() != New(b).Fingerprint() {
+		t.Error("expected equivalent requests to share a fingerprint")
+	}
+
+	c := httptest.NewRequest("GET", "/search?q=wolf&page=3&tag=a&tag=b", nil)
+	c.Header.Set("Accept", "application/json")
+	if New(a).Fingerprint() == New(c).Fingerprint() {
+		t.Error("expected different query values to change the fingerprint")
+	}
+
+	d := httptest.NewRequest("GET", "/search?q=wolf&page=2&tag=a&tag=b", nil)
+	d.Header.Set("Accept", "text/html")
+	if New(a).Fingerprint() == New(d).Fingerprint() {
+		t.Error("expected a different Accept header to change the fingerprint")
+	}
+}
+
+func TestPatternValidation(t *testing.T) {
+	type Theme struct {
+		Color string `validate:"pattern=hex_color"`
+		Zip   string `validate:"pattern=zip_us"`
+	}
+
+	if err := Validate(&Theme{Color: "#aabbcc", Zip: "12345-6789"}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	err := Validate(&Theme{Color: "red"})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "Color" || errs[0].Tag != "pattern" {
+		t.Errorf("expected pattern error for Color, got %v", err)
+	}
+
+	if err := RegisterPattern("sku", `^[A-Z]{3}-[0-9]{4}$`); err != nil {
+		t.Fatalf("RegisterPattern() error = %v", err)
+	}
+	type Product struct {
+		SKU string `validate:"pattern=sku"`
+	}
+	if err := Validate(&Product{SKU: "ABC-1234"}); err != nil {
+		t.Errorf("expected registered pattern to match, got %v", err)
+	}
+	if err := Validate(&Product{SKU: "abc"}); err == nil {
+		t.Error("expected registered pattern to reject value")
+	}
+
+	if err := RegisterPattern("broken", `[`); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestDecodeJSONArray(t *testing.T) {
+	type Item struct {
+		Name string `json:"name" validate:"required" normalize:"trim"`
+	}
+
+	body := `[{"name":" a "},{"name":"b"},{"name":"c"}]`
+	req := httptest.NewRequest("POST", "/bulk", strings.NewReader(body))
+
+	var names []string
+	err := DecodeJSONArray(req, func(decode func(v interface{}) error) error {
+		var item Item
+		if err := decode(&item); err != nil {
+			return err
+		}
+		names = append(names, item.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DecodeJSONArray() error = %v", err)
+	}
+	if strings.Join(names, ",") != "a,b,c" {
+		t.Errorf("expected each to run for a, b, c, got %v", names)
+	}
+
+	req = httptest.NewRequest("POST", "/bulk", strings.NewReader(`[{"name":"a"},{}]`))
+	err = DecodeJSONArray(req, func(decode func(v interface{}) error) error {
+		var item Item
+		return decode(&item)
+	})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Errorf("expected validation error for second element, got %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/bulk", strings.NewReader(`{"name":"a"}`))
+	err = DecodeJSONArray(req, func(decode func(v interface{}) error) error { return nil })
+	var bindErr *BindError
+	if !errors.As(err, &bindErr) {
+		t.Errorf("expected BindError for non-array body, got %v", err)
+	}
+
+	calls := 0
+	req = httptest.NewRequest("POST", "/bulk", strings.NewReader(body))
+	err = DecodeJSONArray(req, func(decode func(v interface{}) error) error {
+		calls++
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("expected undecoded elements to be skipped, got %d calls, err %v", calls, err)
+	}
+}
+
+func TestParseMultipartBounded(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...)
+
+	type upload struct {
+		name string
+		data []byte
+	}
+	newRequest := func(files ...upload) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("title", "holiday")
+		for _, f := range files {
+			part, _ := mw.CreateFormFile("photos", f.name)
+			part.Write(f.data)
+		}
+		mw.Close()
+
+		req := httptest.NewRequest("POST", "/upload", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return req
+	}
+
+	limits := MultipartLimits{
+		MaxFiles:     2,
+		MaxFileSize:  1024,
+		AllowedTypes: []string{"image/*"},
+	}
+
+	form, err := ParseMultipartBounded(newRequest(upload{"a.png", png}, upload{"b.png", png}), limits)
+	if err != nil {
+		t.Fatalf("ParseMultipartBounded() error = %v", err)
+	}
+	if form.Values.Get("title") != "holiday" {
+		t.Errorf("expected title field, got %v", form.Values)
+	}
+	if len(form.Files["photos"]) != 2 || form.Files["photos"][0].ContentType != "image/png" {
+		t.Errorf("expected 2 sniffed PNG files, got %+v", form.Files["photos"])
+	}
+
+	_, err = ParseMultipartBounded(newRequest(upload{"a.png", png}, upload{"b.png", png}, upload{"c.png", png}), limits)
+	if !errors.Is(err, ErrTooManyFiles) {
+		t.Errorf("expected ErrTooManyFiles, got %v", err)
+	}
+
+	big := append(append([]byte{}, png...), bytes.Repeat([]byte{0}, 2048)...)
+	_, err = ParseMultipartBounded(newRequest(upload{"big.png", big}), limits)
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("expected ErrFileTooLarge, got %v", err)
+	}
+
+	// The part header claims an image, but the content is HTML
+	_, err = ParseMultipartBounded(newRequest(upload{"fake.png", []byte("<html><script>alert(1)</script></html>")}), limits)
+	if !errors.Is(err, ErrFileTypeNotAllowed) {
+		t.Errorf("expected ErrFileTypeNotAllowed, got %v", err)
+	}
+
+	limits.MaxTotalSize = 100
+	_, err = ParseMultipartBounded(newRequest(upload{"a.png", png}, upload{"b.png", png}), limits)
+	if !errors.Is(err, ErrRequestTooLarge) {
+		t.Errorf("expected ErrRequestTooLarge for total size, got %v", err)
+	}
+
+	// Without a per-file limit the total budget still bounds the read
+	limits.MaxFileSize = 0
+	huge := append(append([]byte{}, png...), bytes.Repeat([]byte{0}, 1<<20)...)
+	req := newRequest(upload{"huge.png", huge})
+	body := &countingReader{r: req.Body}
+	req.Body = io.NopCloser(body)
+	_, err = ParseMultipartBounded(req, limits)
+	if !errors.Is(err, ErrRequestTooLarge) {
+		t.Errorf("expected ErrRequestTooLarge for oversized file, got %v", err)
+	}
+	if body.n > 64<<10 {
+		t.Errorf("expected the read to stop near the total limit, read %d bytes", body.n)
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
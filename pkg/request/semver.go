@@ -0,0 +1,15 @@
+package request
+
+import "regexp"
+
+// semverRegex matches MAJOR.MINOR.PATCH with optional pre-release and
+// build metadata, as given by the Semantic Versioning 2.0.0 spec
+var semverRegex = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// IsSemVer checks if s is a semantic version such as 1.2.3 or
+// 1.0.0-rc.1+build5. A leading "v" is not accepted.
+func IsSemVer(s string) bool {
+	return semverRegex.MatchString(s)
+}
@@ -38,3 +38,9 @@ func IsYAML(r *http.Request) bool {
 		strings.Contains(contentType, "application/yaml") ||
 		strings.Contains(contentType, "text/yaml")
 }
+
+// IsTOML checks if the request content type is TOML
+func IsTOML(r *http.Request) bool {
+	contentType := GetContentType(r)
+	return strings.Contains(contentType, "application/toml")
+}
@@ -61,6 +61,23 @@ func Validate(obj interface{}) error {
 			continue
 		}
 
+		// Validate embedded structs as part of this one, through a pointer
+		// when possible so normalizing rules can update them in place
+		if fieldType.Anonymous && field.Kind() == reflect.Struct {
+			embedded := field.Interface()
+			if field.CanAddr() {
+				embedded = field.Addr().Interface()
+			}
+			if err := Validate(embedded); err != nil {
+				ve, ok := err.(ValidationErrors)
+				if !ok {
+					return err
+				}
+				errors = append(errors, ve...)
+			}
+			continue
+		}
+
 		validateTag := fieldType.Tag.Get("validate")
 		if validateTag == "" {
 			continue
@@ -196,6 +213,28 @@ func validateRule(field reflect.Value, fieldType reflect.StructField, rule strin
 			}
 		}
 
+	case strings.HasPrefix(rule, "pattern="):
+		name := strings.TrimPrefix(rule, "pattern=")
+		if field.Kind() == reflect.String {
+			value := field.String()
+			// Skip validation if field is empty and not required
+			if value == "" {
+				return nil
+			}
+			re, ok := lookupPattern(name)
+			if !ok {
+				return fmt.Errorf("unknown pattern: %s", name)
+			}
+			if !re.MatchString(value) {
+				return ValidationError{
+					Field:   fieldName,
+					Value:   fieldValue,
+					Message: fmt.Sprintf("must be a valid %s", name),
+					Tag:     "pattern",
+				}
+			}
+		}
+
 	case rule == "numeric":
 		// Skip validation if field is empty and not required
 		if isEmpty(field) {
@@ -227,6 +266,104 @@ func validateRule(field reflect.Value, fieldType reflect.StructField, rule strin
 			}
 		}
 
+	case strings.HasPrefix(rule, "oneof="):
+		allowed := strings.Fields(strings.TrimPrefix(rule, "oneof="))
+		// Skip validation if field is empty and not required
+		if isEmpty(field) {
+			return nil
+		}
+		value := fmt.Sprint(fieldValue)
+		for _, a := range allowed {
+			if value == a {
+				return nil
+			}
+		}
+		return ValidationError{
+			Field:   fieldName,
+			Value:   fieldValue,
+			Message: fmt.Sprintf("must be one of: %s", strings.Join(allowed, ", ")),
+			Tag:     "oneof",
+		}
+
+	case rule == "country", rule == "language":
+		if field.Kind() == reflect.String {
+			value := field.String()
+			// Skip validation if field is empty and not required
+			if value == "" {
+				return nil
+			}
+
+			valid, normalized := IsCountryCode(value), strings.ToUpper(value)
+			message := "must be an ISO 3166-1 alpha-2 country code"
+			if rule == "language" {
+				valid, normalized = IsLanguageCode(value), strings.ToLower(value)
+				message = "must be an ISO 639-1 language code"
+			}
+
+			if !valid {
+				return ValidationError{
+					Field:   fieldName,
+					Value:   fieldValue,
+					Message: message,
+					Tag:     rule,
+				}
+			}
+			if field.CanSet() {
+				field.SetString(normalized)
+			}
+		}
+
+	case rule == "semver":
+		if field.Kind() == reflect.String {
+			value := field.String()
+			// Skip validation if field is empty and not required
+			if value == "" {
+				return nil
+			}
+			if !IsSemVer(value) {
+				return ValidationError{
+					Field:   fieldName,
+					Value:   fieldValue,
+					Message: "must be a semantic version",
+					Tag:     "semver",
+				}
+			}
+		}
+
+	case rule == "luhn":
+		if field.Kind() == reflect.String {
+			value := field.String()
+			// Skip validation if field is empty and not required
+			if value == "" {
+				return nil
+			}
+			if !IsLuhn(value) {
+				return ValidationError{
+					Field:   fieldName,
+					Value:   fieldValue,
+					Message: "must be a valid card number",
+					Tag:     "luhn",
+				}
+			}
+		}
+
+	case rule == "base64", rule == "base64url", rule == "hex":
+		if field.Kind() == reflect.String {
+			value := field.String()
+			// Skip validation if field is empty and not required
+			if value == "" {
+				return nil
+			}
+			if !isEncoded(rule, value) {
+				return ValidationError{
+					Field:   fieldName,
+					Value:   fieldValue,
+					Message: fmt.Sprintf("must be a valid %s string", rule),
+					Tag:     rule,
+				}
+			}
+		}
+
 	case rule == "alphanumeric":
 		if field.Kind() == reflect.String {
 			value := field.String()
@@ -432,3 +569,16 @@ func isAlphanumeric(s string) bool {
 	matched, _ := regexp.MatchString(`^[a-zA-Z0-9]+$`, s)
 	return matched
 }
+
+// isEncoded checks if string is valid in the named encoding
+func isEncoded(encoding, s string) bool {
+	switch encoding {
+	case "base64":
+		return IsBase64(s)
+	case "base64url":
+		return IsBase64URL(s)
+	case "hex":
+		return IsHex(s)
+	}
+	return false
+}
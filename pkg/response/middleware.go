@@ -39,6 +39,23 @@ func (cm *CompressionMiddleware) ShouldCompress(r *http.Request, contentType str
 		return false
 	}
 
+	// Never compress content that is already compressed
+	incompressibleTypes := []string{
+		"image/",
+		"video/",
+		"audio/",
+		"application/zip",
+		"application/gzip",
+		"application/x-gzip",
+		"application/pdf",
+	}
+
+	for _, t := range incompressibleTypes {
+		if strings.HasPrefix(contentType, t) {
+			return false
+		}
+	}
+
 	// Only compress text-based content types
 	compressibleTypes := []string{
 		"text/",
@@ -59,6 +76,11 @@ func (cm *CompressionMiddleware) ShouldCompress(r *http.Request, contentType str
 
 // Wrap wraps an http.ResponseWriter with gzip compression
 func (cm *CompressionMiddleware) Wrap(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	// Skip responses that already carry an encoding
+	if w.Header().Get("Content-Encoding") != "" {
+		return w
+	}
+
 	// Check if we should compress
 	contentType := w.Header().Get("Content-Type")
 	if !cm.ShouldCompress(r, contentType) {
@@ -130,6 +152,10 @@ type CORSMiddleware struct {
 	AllowedMethods []string
 	AllowedHeaders []string
 	MaxAge         int
+
+	// AllowedMethodsFunc, when set, reports the methods actually allowed
+	// for a request (e.g. from the router) and overrides AllowedMethods
+	AllowedMethodsFunc func(r *http.Request) []string
 }
 
 // NewCORSMiddleware creates a new CORS middleware
@@ -152,6 +178,11 @@ func (cm *CORSMiddleware) SetAllowedMethods(methods ...string) {
 	cm.AllowedMethods = methods
 }
 
+// SetAllowedMethodsFunc sets a per-request source of allowed methods
+func (cm *CORSMiddleware) SetAllowedMethodsFunc(fn func(r *http.Request) []string) {
+	cm.AllowedMethodsFunc = fn
+}
+
 // SetAllowedHeaders sets allowed headers
 func (cm *CORSMiddleware) SetAllowedHeaders(headers ...string) {
 	cm.AllowedHeaders = headers
@@ -162,11 +193,18 @@ func (cm *CORSMiddleware) Wrap(w http.ResponseWriter, r *http.Request) http.Resp
 	origin := r.Header.Get("Origin")
 
 	// Check if origin is allowed
-	if cm.isOriginAllowed(origin) {
+	if cm.IsOriginAllowed(origin) {
 		w.Header().Set("Access-Control-Allow-Origin", origin)
 	}
 
-	w.Header().Set("Access-Control-Allow-Methods", strings.Join(cm.AllowedMethods, ", "))
+	methods := cm.AllowedMethods
+	if cm.AllowedMethodsFunc != nil {
+		if allowed := cm.AllowedMethodsFunc(r); len(allowed) > 0 {
+			methods = allowed
+		}
+	}
+
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
 	w.Header().Set("Access-Control-Allow-Headers", strings.Join(cm.AllowedHeaders, ", "))
 
 	if cm.MaxAge > 0 {
@@ -176,8 +214,8 @@ func (cm *CORSMiddleware) Wrap(w http.ResponseWriter, r *http.Request) http.Resp
 	return w
 }
 
-// isOriginAllowed checks if an origin is allowed
-func (cm *CORSMiddleware) isOriginAllowed(origin string) bool {
+// IsOriginAllowed checks if an origin is allowed
+func (cm *CORSMiddleware) IsOriginAllowed(origin string) bool {
 	for _, allowed := range cm.AllowedOrigins {
 		if allowed == "*" || allowed == origin {
 			return true
@@ -0,0 +1,98 @@
+package response
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompressionShouldCompress(t *testing.T) {
+	cm := NewCompressionMiddleware(gzip.DefaultCompression)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	tests := []struct {
+		contentType string
+		expected    bool
+	}{
+		{"application/json; charset=utf-8", true},
+		{"text/html", true},
+		{"image/png", false},
+		{"image/svg+xml", false},
+		{"video/mp4", false},
+		{"application/zip", false},
+		{"application/gzip", false},
+	}
+
+	for _, tt := range tests {
+		if result := cm.ShouldCompress(req, tt.contentType); result != tt.expected {
+			t.Errorf("ShouldCompress(%s) = %v, expected %v", tt.contentType, result, tt.expected)
+		}
+	}
+}
+
+func TestCompressionWrapSkipsEncoded(t *testing.T) {
+	cm := NewCompressionMiddleware(gzip.DefaultCompression)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	t.Run("png", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		w.Header().Set("Content-Type", "image/png")
+
+		if wrapped := cm.Wrap(w, req); wrapped != w {
+			t.Error("expected PNG response to be left untouched")
+		}
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("expected no Content-Encoding, got %s", w.Header().Get("Content-Encoding"))
+		}
+	})
+
+	t.Run("already gzipped", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+
+		if wrapped := cm.Wrap(w, req); wrapped != w {
+			t.Error("expected already-encoded response to be left untouched")
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		w.Header().Set("Content-Type", "application/json")
+
+		if wrapped := cm.Wrap(w, req); wrapped == w {
+			t.Error("expected JSON response to be compressed")
+		}
+		if w.Header().Get("Content-Encoding") != "gzip" {
+			t.Errorf("expected gzip Content-Encoding, got %s", w.Header().Get("Content-Encoding"))
+		}
+	})
+}
+
+func TestCORSAllowedMethodsFunc(t *testing.T) {
+	cm := NewCORSMiddleware()
+
+	req := httptest.NewRequest("OPTIONS", "/items", nil)
+	req.Header.Set("Origin", "https://example.com")
+
+	w := httptest.NewRecorder()
+	cm.Wrap(w, req)
+	if w.Header().Get("Access-Control-Allow-Methods") != "GET, POST, PUT, DELETE, OPTIONS" {
+		t.Errorf("expected static methods, got %s", w.Header().Get("Access-Control-Allow-Methods"))
+	}
+
+	cm.SetAllowedMethodsFunc(func(r *http.Request) []string {
+		return []string{"GET", "POST"}
+	})
+
+	w = httptest.NewRecorder()
+	cm.Wrap(w, req)
+	if w.Header().Get("Access-Control-Allow-Methods") != "GET, POST" {
+		t.Errorf("expected route methods, got %s", w.Header().Get("Access-Control-Allow-Methods"))
+	}
+}
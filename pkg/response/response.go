@@ -1,22 +1,31 @@
 package response
 
 import (
-	"encoding/json"
+	"archive/zip"
+	"context"
+	"encoding/csv"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
+
+	"github.com/aliwert/go-wolf/pkg/codec"
 )
 
 // JSON sends a JSON response
 func JSON(w http.ResponseWriter, code int, obj interface{}) error {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(code)
-	return json.NewEncoder(w).Encode(obj)
+	return codec.JSON().NewEncoder(w).Encode(obj)
 }
 
 // JSONPretty sends a pretty-formatted JSON response
@@ -24,14 +33,150 @@ func JSONPretty(w http.ResponseWriter, code int, obj interface{}) error {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(code)
 
-	encoder := json.NewEncoder(w)
+	encoder := codec.JSON().NewEncoder(w)
 	encoder.SetIndent("", "  ")
 	return encoder.Encode(obj)
 }
 
+// jsonArrayFlushInterval is the number of elements written between flushes
+const jsonArrayFlushInterval = 100
+
+// JSONArray streams a JSON array, encoding one element at a time as
+// returned by next until it reports false
+func JSONArray(w http.ResponseWriter, code int, next func() (interface{}, bool)) error {
+	return JSONArrayContext(context.Background(), w, code, next)
+}
+
+// JSONArrayContext streams a JSON array like JSONArray, stopping with the
+// context's error once ctx is done, e.g. when the client has gone away.
+// The array is left unterminated in that case.
+func JSONArrayContext(ctx context.Context, w http.ResponseWriter, code int, next func() (interface{}, bool)) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+
+	flusher, _ := w.(http.Flusher)
+
+	if _, err := w.Write([]byte("[")); err != nil {
+		return err
+	}
+
+	for i := 0; ; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		item, ok := next()
+		if !ok {
+			break
+		}
+
+		data, err := codec.JSON().Marshal(item)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			if _, err := w.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+
+		if flusher != nil && (i+1)%jsonArrayFlushInterval == 0 {
+			flusher.Flush()
+		}
+	}
+
+	if _, err := w.Write([]byte("]")); err != nil {
+		return err
+	}
+
+	if flusher != nil {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// NDJSON streams newline-delimited JSON, one element per line as returned
+// by next until it reports false
+func NDJSON(w http.ResponseWriter, code int, next func() (interface{}, bool)) error {
+	return NDJSONContext(context.Background(), w, code, next)
+}
+
+// NDJSONContext streams newline-delimited JSON like NDJSON, stopping with
+// the context's error once ctx is done
+func NDJSONContext(ctx context.Context, w http.ResponseWriter, code int, next func() (interface{}, bool)) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(code)
+
+	flusher, _ := w.(http.Flusher)
+	encoder := codec.JSON().NewEncoder(w)
+
+	for i := 0; ; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		item, ok := next()
+		if !ok {
+			break
+		}
+
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+
+		if flusher != nil && (i+1)%jsonArrayFlushInterval == 0 {
+			flusher.Flush()
+		}
+	}
+
+	if flusher != nil {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// defaultCharset is the charset used by String responses
+var defaultCharset = "utf-8"
+
+// SetDefaultCharset sets the charset used by String responses
+func SetDefaultCharset(charset string) {
+	if charset == "" {
+		charset = "utf-8"
+	}
+	defaultCharset = charset
+}
+
+// ContentTypeOption overrides the content type of a String response
+type ContentTypeOption string
+
+// WithContentType returns an option that overrides the String content type.
+// The default charset is appended unless the type already specifies one.
+func WithContentType(contentType string) ContentTypeOption {
+	return ContentTypeOption(contentType)
+}
+
 // String sends a plain text response
 func String(w http.ResponseWriter, code int, format string, values ...interface{}) error {
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	contentType := "text/plain"
+	var args []interface{}
+	for _, v := range values {
+		if opt, ok := v.(ContentTypeOption); ok {
+			contentType = string(opt)
+			continue
+		}
+		args = append(args, v)
+	}
+	values = args
+
+	if !strings.Contains(contentType, "charset=") {
+		contentType += "; charset=" + defaultCharset
+	}
+
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(code)
 
 	if len(values) > 0 {
@@ -60,6 +205,36 @@ func XML(w http.ResponseWriter, code int, obj interface{}) error {
 	return encoder.Encode(obj)
 }
 
+// XMLWithRoot sends an XML response with an XML declaration, wrapping the
+// encoded obj in a root element of the given name
+func XMLWithRoot(w http.ResponseWriter, code int, root string, obj interface{}) error {
+	if root == "" {
+		return fmt.Errorf("XML root element name cannot be empty")
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(code)
+
+	if _, err := io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	start := xml.StartElement{Name: xml.Name{Local: root}}
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encoder.Encode(obj); err != nil {
+		return err
+	}
+	if err := encoder.EncodeToken(start.End()); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
 // YAML sends a YAML response
 func YAML(w http.ResponseWriter, code int, obj interface{}) error {
 	w.Header().Set("Content-Type", "application/x-yaml; charset=utf-8")
@@ -70,14 +245,60 @@ func YAML(w http.ResponseWriter, code int, obj interface{}) error {
 	return encoder.Encode(obj)
 }
 
-// Data sends raw data response
+// TOML sends a TOML response
+func TOML(w http.ResponseWriter, code int, obj interface{}) error {
+	w.Header().Set("Content-Type", "application/toml; charset=utf-8")
+	w.WriteHeader(code)
+
+	return toml.NewEncoder(w).Encode(obj)
+}
+
+// Data sends raw data response, defaulting to application/octet-stream
 func Data(w http.ResponseWriter, code int, contentType string, data []byte) error {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
 	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(code)
 	_, err := w.Write(data)
 	return err
 }
 
+// SafeDataOption adjusts how SafeData serves its payload
+type SafeDataOption int
+
+// AllowInline lets SafeData serve active content such as HTML or SVG inline
+const AllowInline SafeDataOption = iota + 1
+
+// SafeData sends raw data like Data, but disables MIME sniffing and serves
+// content a browser could execute (HTML, SVG, XML) as a download unless
+// AllowInline is passed. Use it for user-uploaded content.
+func SafeData(w http.ResponseWriter, code int, contentType string, data []byte, opts ...SafeDataOption) error {
+	allowInline := false
+	for _, opt := range opts {
+		if opt == AllowInline {
+			allowInline = true
+		}
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if !allowInline && isActiveContent(contentType) {
+		w.Header().Set("Content-Disposition", "attachment")
+	}
+
+	return Data(w, code, contentType, data)
+}
+
+// isActiveContent reports whether a content type can run script in a browser
+func isActiveContent(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "image/svg+xml", "text/xml", "application/xml":
+		return true
+	}
+	return false
+}
+
 // Stream sends a streaming response
 func Stream(w http.ResponseWriter, code int, contentType string, reader io.Reader) error {
 	w.Header().Set("Content-Type", contentType)
@@ -91,6 +312,63 @@ func File(w http.ResponseWriter, r *http.Request, filePath string) {
 	http.ServeFile(w, r, filePath)
 }
 
+// streamChunkSize is the number of bytes StreamFile copies between
+// progress reports
+const streamChunkSize = 32 * 1024
+
+// StreamFile streams a file to the client in chunks, calling onProgress
+// with the bytes sent so far and the file size after each chunk. It stops
+// early if the request context is cancelled.
+func StreamFile(w http.ResponseWriter, r *http.Request, filePath string, onProgress func(sent, total int64)) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	total := info.Size()
+
+	w.Header().Set("Content-Type", GuessContentType(filePath))
+	w.Header().Set("Content-Length", strconv.FormatInt(total, 10))
+	w.WriteHeader(http.StatusOK)
+
+	buf := make([]byte, streamChunkSize)
+	var sent int64
+	for {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+
+		n, readErr := file.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+			sent += int64(n)
+			if onProgress != nil {
+				onProgress(sent, total)
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// ServeContent serves content with Range, If-Modified-Since and
+// If-None-Match support, so byte-range resume works for generated content
+func ServeContent(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, content io.ReadSeeker) error {
+	http.ServeContent(w, r, name, modTime, content)
+	return nil
+}
+
 // Download sends a file as attachment
 func Download(w http.ResponseWriter, r *http.Request, filePath, filename string) {
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
@@ -99,6 +377,73 @@ func Download(w http.ResponseWriter, r *http.Request, filePath, filename string)
 	http.ServeFile(w, r, filePath)
 }
 
+// ZipStream streams a zip archive as an attachment, letting add write
+// entries directly to the response without buffering the archive
+func ZipStream(w http.ResponseWriter, filename string, add func(zw *zip.Writer) error) error {
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Header().Set("Content-Description", "File Transfer")
+	w.Header().Set("Content-Type", "application/zip")
+	w.WriteHeader(http.StatusOK)
+
+	zw := zip.NewWriter(w)
+	if err := add(zw); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// csvFlushInterval is the number of rows written between flushes
+const csvFlushInterval = 100
+
+// CSVStream streams a CSV download, writing header and then each row
+// returned by next until it reports false
+func CSVStream(w http.ResponseWriter, filename string, header []string, next func() ([]string, bool)) error {
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	cw := csv.NewWriter(w)
+
+	if len(header) > 0 {
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; ; i++ {
+		row, ok := next()
+		if !ok {
+			break
+		}
+
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+
+		if (i+1)%csvFlushInterval == 0 {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	if flusher != nil {
+		flusher.Flush()
+	}
+	return nil
+}
+
 // Error sends an error response
 func Error(w http.ResponseWriter, code int, message string) error {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -111,7 +456,131 @@ func Error(w http.ResponseWriter, code int, message string) error {
 		},
 	}
 
-	return json.NewEncoder(w).Encode(response)
+	return codec.JSON().NewEncoder(w).Encode(response)
+}
+
+// TooManyRequests sends a 429 response telling the client when to retry
+func TooManyRequests(w http.ResponseWriter, retryAfter time.Duration) error {
+	return TooManyRequestsFor(w, nil, retryAfter)
+}
+
+// TooManyRequestsFor sends a 429 response like TooManyRequests, rendered
+// as JSON or plain text according to the request's Accept header
+func TooManyRequestsFor(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	return statusError(w, r, http.StatusTooManyRequests, map[string]interface{}{
+		"retry_after": seconds,
+	})
+}
+
+// UnsupportedMediaType sends a 415 response, rendered as JSON or plain
+// text according to the request's Accept header
+func UnsupportedMediaType(w http.ResponseWriter, r *http.Request) error {
+	return statusError(w, r, http.StatusUnsupportedMediaType, nil)
+}
+
+// PayloadTooLarge sends a 413 response reporting the size limit in bytes,
+// rendered as JSON or plain text according to the request's Accept header
+func PayloadTooLarge(w http.ResponseWriter, r *http.Request, limit int64) error {
+	return statusError(w, r, http.StatusRequestEntityTooLarge, map[string]interface{}{
+		"limit": limit,
+	})
+}
+
+// statusError writes a status response in the error envelope used by
+// Error, or as plain text when the client does not want JSON
+func statusError(w http.ResponseWriter, r *http.Request, code int, extra map[string]interface{}) error {
+	message := http.StatusText(code)
+
+	if !wantsJSON(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		_, err := w.Write([]byte(message))
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+
+	body := map[string]interface{}{
+		"code":    code,
+		"message": message,
+	}
+	for key, value := range extra {
+		body[key] = value
+	}
+
+	return codec.JSON().NewEncoder(w).Encode(map[string]interface{}{"error": body})
+}
+
+// wantsJSON reports whether a response to r should be JSON. Clients that
+// name JSON get it; clients that only list text types get plain text;
+// anything else, including a missing request or Accept header, gets JSON.
+func wantsJSON(r *http.Request) bool {
+	if r == nil {
+		return true
+	}
+
+	accept := strings.ToLower(r.Header.Get("Accept"))
+	switch {
+	case strings.Contains(accept, "application/json"), strings.Contains(accept, "+json"):
+		return true
+	case strings.Contains(accept, "text/"):
+		return false
+	default:
+		return true
+	}
+}
+
+// ProblemDetails is an RFC 7807 problem details object. Extensions are written
+// as additional top-level members and cannot replace the standard ones.
+type ProblemDetails struct {
+	Type       string                 `json:"type,omitempty"`
+	Title      string                 `json:"title,omitempty"`
+	Status     int                    `json:"status,omitempty"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// ProblemContentType is the media type of RFC 7807 problem responses
+const ProblemContentType = "application/problem+json"
+
+// Problem sends p as an application/problem+json response. Status
+// defaults to the response code and Title to its status text.
+func Problem(w http.ResponseWriter, status int, p ProblemDetails) error {
+	if p.Status == 0 {
+		p.Status = status
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(status)
+	}
+
+	body := make(map[string]interface{}, len(p.Extensions)+5)
+	for key, value := range p.Extensions {
+		body[key] = value
+	}
+	for key, value := range map[string]string{
+		"type":     p.Type,
+		"title":    p.Title,
+		"detail":   p.Detail,
+		"instance": p.Instance,
+	} {
+		delete(body, key)
+		if value != "" {
+			body[key] = value
+		}
+	}
+	body["status"] = p.Status
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+	return codec.JSON().NewEncoder(w).Encode(body)
 }
 
 // Success sends a success response
@@ -124,7 +593,7 @@ func Success(w http.ResponseWriter, code int, data interface{}) error {
 		"data":    data,
 	}
 
-	return json.NewEncoder(w).Encode(response)
+	return codec.JSON().NewEncoder(w).Encode(response)
 }
 
 // NoContent sends a 204 No Content response
@@ -168,6 +637,22 @@ func Attachment(w http.ResponseWriter, r *http.Request, filePath, filename strin
 	return nil
 }
 
+// Inline serves a file for inline display, e.g. a PDF or image shown in
+// the browser rather than downloaded
+func Inline(w http.ResponseWriter, r *http.Request, filePath string) error {
+	return InlineAs(w, r, filePath, filepath.Base(filePath))
+}
+
+// InlineAs serves a file for inline display under the given filename,
+// which also determines the content type
+func InlineAs(w http.ResponseWriter, r *http.Request, filePath, filename string) error {
+	w.Header().Set("Content-Type", GuessContentType(filename))
+	SetInlineHeaders(w, filename)
+
+	http.ServeFile(w, r, filePath)
+	return nil
+}
+
 // JSONP sends a JSONP response
 func JSONP(w http.ResponseWriter, code int, callback string, obj interface{}) error {
 	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
@@ -177,7 +662,7 @@ func JSONP(w http.ResponseWriter, code int, callback string, obj interface{}) er
 		callback = "callback"
 	}
 
-	data, err := json.Marshal(obj)
+	data, err := codec.JSON().Marshal(obj)
 	if err != nil {
 		return err
 	}
@@ -205,6 +690,7 @@ func getContentTypeFromExt(ext string) string {
 		".xml":  "application/xml",
 		".yaml": "application/x-yaml",
 		".yml":  "application/x-yaml",
+		".toml": "application/toml",
 		".png":  "image/png",
 		".jpg":  "image/jpeg",
 		".jpeg": "image/jpeg",
@@ -1,19 +1,31 @@
 package response
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
+	"io"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
+
+	"github.com/aliwert/go-wolf/pkg/codec"
 )
 
 type TestData struct {
-	Name  string `json:"name" xml:"name" yaml:"name"`
-	Value int    `json:"value" xml:"value" yaml:"value"`
+	Name  string `json:"name" xml:"name" yaml:"name" toml:"name"`
+	Value int    `json:"value" xml:"value" yaml:"value" toml:"value"`
 }
 
 func TestJSON(t *testing.T) {
@@ -59,6 +71,107 @@ func TestJSONPretty(t *testing.T) {
 	}
 }
 
+func TestJSONArray(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []TestData
+	}{
+		{"empty", []TestData{}},
+		{"single", []TestData{{Name: "a", Value: 1}}},
+		{"multiple", []TestData{{Name: "a", Value: 1}, {Name: "b", Value: 2}, {Name: "c", Value: 3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			i := 0
+			err := JSONArray(w, 200, func() (interface{}, bool) {
+				if i >= len(tt.items) {
+					return nil, false
+				}
+				i++
+				return tt.items[i-1], true
+			})
+			if err != nil {
+				t.Fatalf("JSONArray() error = %v", err)
+			}
+
+			expected, _ := json.Marshal(tt.items)
+			if w.Body.String() != string(expected) {
+				t.Errorf("expected %s, got %s", expected, w.Body.String())
+			}
+
+			if !strings.Contains(w.Header().Get("Content-Type"), "application/json") {
+				t.Errorf("expected JSON content type, got %s", w.Header().Get("Content-Type"))
+			}
+			if !w.Flushed {
+				t.Error("expected response to be flushed")
+			}
+		})
+	}
+}
+
+func TestStreamingCancellation(t *testing.T) {
+	streams := []struct {
+		name   string
+		stream func(ctx context.Context, w http.ResponseWriter, next func() (interface{}, bool)) error
+	}{
+		{"JSONArrayContext", func(ctx context.Context, w http.ResponseWriter, next func() (interface{}, bool)) error {
+			return JSONArrayContext(ctx, w, 200, next)
+		}},
+		{"NDJSONContext", func(ctx context.Context, w http.ResponseWriter, next func() (interface{}, bool)) error {
+			return NDJSONContext(ctx, w, 200, next)
+		}},
+	}
+
+	for _, s := range streams {
+		t.Run(s.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			produced := 0
+			err := s.stream(ctx, httptest.NewRecorder(), func() (interface{}, bool) {
+				produced++
+				if produced == 3 {
+					cancel()
+				}
+				return TestData{Name: "item", Value: produced}, true
+			})
+
+			if err != context.Canceled {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+			if produced != 3 {
+				t.Errorf("expected streaming to stop after 3 elements, produced %d", produced)
+			}
+		})
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	items := []TestData{{Name: "a", Value: 1}, {Name: "b", Value: 2}}
+	w := httptest.NewRecorder()
+	i := 0
+	err := NDJSON(w, 200, func() (interface{}, bool) {
+		if i >= len(items) {
+			return nil, false
+		}
+		i++
+		return items[i-1], true
+	})
+	if err != nil {
+		t.Fatalf("NDJSON() error = %v", err)
+	}
+
+	expected := "{\"name\":\"a\",\"value\":1}\n{\"name\":\"b\",\"value\":2}\n"
+	if w.Body.String() != expected {
+		t.Errorf("expected %q, got %q", expected, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("expected application/x-ndjson, got %s", w.Header().Get("Content-Type"))
+	}
+}
+
 func TestXML(t *testing.T) {
 	data := TestData{Name: "test", Value: 123}
 	w := httptest.NewRecorder()
@@ -87,6 +200,37 @@ func TestXML(t *testing.T) {
 	}
 }
 
+func TestXMLWithRoot(t *testing.T) {
+	data := TestData{Name: "test", Value: 123}
+	w := httptest.NewRecorder()
+
+	if err := XMLWithRoot(w, 200, "response", data); err != nil {
+		t.Fatalf("XMLWithRoot() error = %v", err)
+	}
+
+	body := w.Body.String()
+	if !strings.HasPrefix(body, `<?xml version="1.0" encoding="UTF-8"?>`) {
+		t.Errorf("expected XML declaration, got %s", body)
+	}
+	if !strings.Contains(body, "<response>") || !strings.HasSuffix(strings.TrimSpace(body), "</response>") {
+		t.Errorf("expected response root element, got %s", body)
+	}
+
+	var result struct {
+		Data TestData `xml:"TestData"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to unmarshal XML response: %v", err)
+	}
+	if result.Data != data {
+		t.Errorf("expected %+v inside root, got %+v", data, result.Data)
+	}
+
+	if err := XMLWithRoot(httptest.NewRecorder(), 200, "", data); err == nil {
+		t.Error("expected error for empty root name")
+	}
+}
+
 func TestYAML(t *testing.T) {
 	data := TestData{Name: "test", Value: 123}
 	w := httptest.NewRecorder()
@@ -115,6 +259,30 @@ func TestYAML(t *testing.T) {
 	}
 }
 
+func TestTOML(t *testing.T) {
+	data := TestData{Name: "test", Value: 123}
+	w := httptest.NewRecorder()
+
+	err := TOML(w, 200, data)
+	if err != nil {
+		t.Fatalf("TOML() error = %v", err)
+	}
+
+	contentType := w.Header().Get("Content-Type")
+	if !strings.Contains(contentType, "application/toml") {
+		t.Errorf("expected TOML content type, got %s", contentType)
+	}
+
+	var result TestData
+	if _, err := toml.Decode(w.Body.String(), &result); err != nil {
+		t.Fatalf("failed to unmarshal TOML response: %v", err)
+	}
+
+	if result.Name != data.Name || result.Value != data.Value {
+		t.Errorf("expected %+v, got %+v", data, result)
+	}
+}
+
 func TestString(t *testing.T) {
 	w := httptest.NewRecorder()
 
@@ -139,6 +307,48 @@ func TestString(t *testing.T) {
 	}
 }
 
+func TestStringContentType(t *testing.T) {
+	t.Run("custom charset", func(t *testing.T) {
+		SetDefaultCharset("iso-8859-1")
+		defer SetDefaultCharset("")
+
+		w := httptest.NewRecorder()
+		if err := String(w, 200, "hello"); err != nil {
+			t.Fatalf("String() error = %v", err)
+		}
+
+		expected := "text/plain; charset=iso-8859-1"
+		if w.Header().Get("Content-Type") != expected {
+			t.Errorf("expected content type %s, got %s", expected, w.Header().Get("Content-Type"))
+		}
+	})
+
+	t.Run("markdown override", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		if err := String(w, 200, "# %s", "Title", WithContentType("text/markdown")); err != nil {
+			t.Fatalf("String() error = %v", err)
+		}
+
+		expected := "text/markdown; charset=utf-8"
+		if w.Header().Get("Content-Type") != expected {
+			t.Errorf("expected content type %s, got %s", expected, w.Header().Get("Content-Type"))
+		}
+		if w.Body.String() != "# Title" {
+			t.Errorf("expected body '# Title', got %s", w.Body.String())
+		}
+	})
+
+	t.Run("explicit charset kept", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		String(w, 200, "hello", WithContentType("text/csv; charset=utf-16"))
+
+		expected := "text/csv; charset=utf-16"
+		if w.Header().Get("Content-Type") != expected {
+			t.Errorf("expected content type %s, got %s", expected, w.Header().Get("Content-Type"))
+		}
+	})
+}
+
 func TestHTML(t *testing.T) {
 	w := httptest.NewRecorder()
 	htmlContent := "<h1>Hello, World!</h1>"
@@ -186,6 +396,18 @@ func TestDataResponse(t *testing.T) {
 	}
 }
 
+func TestDataDefaultContentType(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	if err := Data(w, 200, "", []byte("raw")); err != nil {
+		t.Fatalf("Data() error = %v", err)
+	}
+
+	if w.Header().Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("expected application/octet-stream, got %s", w.Header().Get("Content-Type"))
+	}
+}
+
 func TestStream(t *testing.T) {
 	w := httptest.NewRecorder()
 	data := "streaming data"
@@ -244,6 +466,87 @@ func TestError(t *testing.T) {
 	}
 }
 
+func TestTooManyRequests(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	err := TooManyRequests(w, 1500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("TooManyRequests() error = %v", err)
+	}
+
+	if w.Code != 429 {
+		t.Errorf("expected status 429, got %d", w.Code)
+	}
+
+	if w.Header().Get("Retry-After") != "2" {
+		t.Errorf("expected Retry-After 2, got %s", w.Header().Get("Retry-After"))
+	}
+
+	var result map[string]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if result["error"]["retry_after"] != float64(2) {
+		t.Errorf("expected retry_after 2, got %v", result["error"]["retry_after"])
+	}
+}
+
+func TestStatusHelpersNegotiation(t *testing.T) {
+	helpers := []struct {
+		name   string
+		code   int
+		render func(w http.ResponseWriter, r *http.Request) error
+	}{
+		{"TooManyRequestsFor", http.StatusTooManyRequests, func(w http.ResponseWriter, r *http.Request) error {
+			return TooManyRequestsFor(w, r, time.Second)
+		}},
+		{"UnsupportedMediaType", http.StatusUnsupportedMediaType, UnsupportedMediaType},
+		{"PayloadTooLarge", http.StatusRequestEntityTooLarge, func(w http.ResponseWriter, r *http.Request) error {
+			return PayloadTooLarge(w, r, 1024)
+		}},
+	}
+
+	for _, h := range helpers {
+		t.Run(h.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", nil)
+			req.Header.Set("Accept", "application/json")
+			w := httptest.NewRecorder()
+
+			if err := h.render(w, req); err != nil {
+				t.Fatalf("%s() error = %v", h.name, err)
+			}
+			if w.Code != h.code {
+				t.Errorf("expected status %d, got %d", h.code, w.Code)
+			}
+			if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+				t.Errorf("expected JSON for API client, got %s", w.Header().Get("Content-Type"))
+			}
+			var result map[string]map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if result["error"]["code"] != float64(h.code) {
+				t.Errorf("expected error code %d, got %v", h.code, result["error"]["code"])
+			}
+
+			req = httptest.NewRequest("POST", "/", nil)
+			req.Header.Set("Accept", "text/html,text/plain")
+			w = httptest.NewRecorder()
+
+			if err := h.render(w, req); err != nil {
+				t.Fatalf("%s() error = %v", h.name, err)
+			}
+			if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+				t.Errorf("expected plain text, got %s", w.Header().Get("Content-Type"))
+			}
+			if w.Body.String() != http.StatusText(h.code) {
+				t.Errorf("expected body %q, got %q", http.StatusText(h.code), w.Body.String())
+			}
+		})
+	}
+}
+
 func TestSuccess(t *testing.T) {
 	w := httptest.NewRecorder()
 	data := TestData{Name: "test", Value: 123}
@@ -378,3 +681,435 @@ func TestGetContentTypeFromExt(t *testing.T) {
 		})
 	}
 }
+
+type spyCodec struct {
+	codec.Std
+	marshals int
+	encodes  int
+}
+
+func (s *spyCodec) Marshal(v interface{}) ([]byte, error) {
+	s.marshals++
+	return s.Std.Marshal(v)
+}
+
+func (s *spyCodec) NewEncoder(w io.Writer) codec.Encoder {
+	s.encodes++
+	return s.Std.NewEncoder(w)
+}
+
+func TestJSONCodec(t *testing.T) {
+	spy := &spyCodec{}
+	codec.SetJSON(spy)
+	defer codec.SetJSON(nil)
+
+	w := httptest.NewRecorder()
+	if err := JSON(w, 200, TestData{Name: "test", Value: 1}); err != nil {
+		t.Fatalf("JSON() error = %v", err)
+	}
+	if err := Error(httptest.NewRecorder(), 400, "bad"); err != nil {
+		t.Fatalf("Error() error = %v", err)
+	}
+	if err := JSONP(httptest.NewRecorder(), 200, "cb", TestData{}); err != nil {
+		t.Fatalf("JSONP() error = %v", err)
+	}
+
+	if spy.encodes != 2 {
+		t.Errorf("expected 2 encoder uses, got %d", spy.encodes)
+	}
+	if spy.marshals != 1 {
+		t.Errorf("expected 1 marshal, got %d", spy.marshals)
+	}
+
+	var result TestData
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if result.Name != "test" {
+		t.Errorf("expected name test, got %s", result.Name)
+	}
+}
+
+func TestZipStream(t *testing.T) {
+	files := map[string]string{
+		"a.txt": "first file",
+		"b.txt": "second file",
+	}
+
+	w := httptest.NewRecorder()
+	err := ZipStream(w, "bundle.zip", func(zw *zip.Writer) error {
+		for _, name := range []string{"a.txt", "b.txt"} {
+			f, err := zw.Create(name)
+			if err != nil {
+				return err
+			}
+			if _, err := f.Write([]byte(files[name])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ZipStream() error = %v", err)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("expected application/zip, got %s", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="bundle.zip"` {
+		t.Errorf("unexpected Content-Disposition: %s", cd)
+	}
+
+	body := w.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("failed to read zip: %v", err)
+	}
+	if len(zr.File) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(zr.File))
+	}
+
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", f.Name, err)
+		}
+		if string(data) != files[f.Name] {
+			t.Errorf("%s: expected %q, got %q", f.Name, files[f.Name], data)
+		}
+	}
+}
+
+func TestInline(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		file        string
+		filename    string
+		contentType string
+		disposition string
+	}{
+		{"report.pdf", "", "application/pdf", `inline; filename="report.pdf"`},
+		{"logo.png", "", "image/png", `inline; filename="logo.png"`},
+		{"upload.bin", "photo.png", "image/png", `inline; filename="photo.png"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte("content"), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/"+tt.file, nil)
+
+			var err error
+			if tt.filename == "" {
+				err = Inline(w, r, path)
+			} else {
+				err = InlineAs(w, r, path, tt.filename)
+			}
+			if err != nil {
+				t.Fatalf("Inline() error = %v", err)
+			}
+
+			if w.Code != 200 {
+				t.Errorf("expected status 200, got %d", w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("expected Content-Type %s, got %s", tt.contentType, ct)
+			}
+			if cd := w.Header().Get("Content-Disposition"); cd != tt.disposition {
+				t.Errorf("expected Content-Disposition %s, got %s", tt.disposition, cd)
+			}
+			if w.Body.String() != "content" {
+				t.Errorf("unexpected body %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSafeData(t *testing.T) {
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)
+
+	t.Run("svg defaults to attachment", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		if err := SafeData(w, 200, "image/svg+xml", svg); err != nil {
+			t.Fatalf("SafeData() error = %v", err)
+		}
+
+		if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Errorf("expected nosniff, got %q", got)
+		}
+		if got := w.Header().Get("Content-Disposition"); got != "attachment" {
+			t.Errorf("expected attachment disposition, got %q", got)
+		}
+		if got := w.Header().Get("Content-Type"); got != "image/svg+xml" {
+			t.Errorf("expected image/svg+xml, got %q", got)
+		}
+	})
+
+	t.Run("html with charset", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		SafeData(w, 200, "text/html; charset=utf-8", []byte("<p>hi</p>"))
+
+		if got := w.Header().Get("Content-Disposition"); got != "attachment" {
+			t.Errorf("expected attachment disposition, got %q", got)
+		}
+	})
+
+	t.Run("allow inline", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		SafeData(w, 200, "image/svg+xml", svg, AllowInline)
+
+		if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Errorf("expected nosniff, got %q", got)
+		}
+		if got := w.Header().Get("Content-Disposition"); got != "" {
+			t.Errorf("expected no disposition, got %q", got)
+		}
+	})
+
+	t.Run("passive content", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		SafeData(w, 200, "image/png", []byte{0x89, 'P', 'N', 'G'})
+
+		if got := w.Header().Get("Content-Disposition"); got != "" {
+			t.Errorf("expected no disposition, got %q", got)
+		}
+	})
+}
+
+func TestRenderLocalized(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"home.html":    "Welcome {{.}}",
+		"home.en.html": "Hello {{.}}",
+		"home.fr.html": "Bonjour {{.}}",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write template: %v", err)
+		}
+	}
+
+	renderer := NewTemplateRenderer(dir)
+	if err := renderer.LoadTemplates(); err != nil {
+		t.Fatalf("LoadTemplates() error = %v", err)
+	}
+
+	tests := []struct {
+		lang     string
+		expected string
+	}{
+		{"en", "Hello Ada"},
+		{"fr", "Bonjour Ada"},
+		{"fr-CA", "Bonjour Ada"},
+		{"de", "Welcome Ada"},
+		{"", "Welcome Ada"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := renderer.RenderLocalized(&buf, "home", tt.lang, "Ada"); err != nil {
+			t.Fatalf("RenderLocalized(%q) error = %v", tt.lang, err)
+		}
+		if buf.String() != tt.expected {
+			t.Errorf("RenderLocalized(%q) = %q, expected %q", tt.lang, buf.String(), tt.expected)
+		}
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "500.html"), []byte("<h1>Oops: {{.}}</h1>"), 0o644); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+
+	previous := DefaultTemplateRenderer
+	defer func() { DefaultTemplateRenderer = previous }()
+	if err := SetDefaultTemplateDir(dir); err != nil {
+		t.Fatalf("SetDefaultTemplateDir() error = %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	if err := RenderError(w, 500, "500", "database down"); err != nil {
+		t.Fatalf("RenderError() error = %v", err)
+	}
+	if w.Code != 500 || w.Body.String() != "<h1>Oops: database down</h1>" {
+		t.Errorf("RenderError() = %d %q, expected rendered 500 template", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q, expected text/html", ct)
+	}
+
+	w = httptest.NewRecorder()
+	if err := RenderError(w, 404, "404", nil); err != nil {
+		t.Fatalf("RenderError() error = %v", err)
+	}
+	if w.Code != 404 || w.Body.String() != "Not Found" {
+		t.Errorf("RenderError() fallback = %d %q, expected plain 404", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q, expected text/plain", ct)
+	}
+}
+
+func TestProblem(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := Problem(w, http.StatusForbidden, ProblemDetails{
+		Type:     "https://example.com/probs/out-of-credit",
+		Detail:   "Your current balance is 30, but that costs 50.",
+		Instance: "/account/12345/msgs/abc",
+		Extensions: map[string]interface{}{
+			"balance":  30,
+			"accounts": []string{"/account/12345", "/account/67890"},
+			"status":   "ignored",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Problem() error = %v", err)
+	}
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("expected application/problem+json, got %q", ct)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	expected := map[string]interface{}{
+		"type":     "https://example.com/probs/out-of-credit",
+		"title":    "Forbidden",
+		"status":   float64(403),
+		"detail":   "Your current balance is 30, but that costs 50.",
+		"instance": "/account/12345/msgs/abc",
+		"balance":  float64(30),
+		"accounts": []interface{}{"/account/12345", "/account/67890"},
+	}
+	if !reflect.DeepEqual(body, expected) {
+		t.Errorf("Problem() body = %v, expected %v", body, expected)
+	}
+}
+
+func TestServeContent(t *testing.T) {
+	content := strings.NewReader("0123456789")
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	r := httptest.NewRequest("GET", "/report.txt", nil)
+	r.Header.Set("Range", "bytes=0-3")
+	w := httptest.NewRecorder()
+
+	if err := ServeContent(w, r, "report.txt", modTime, content); err != nil {
+		t.Fatalf("ServeContent() error = %v", err)
+	}
+
+	if w.Code != 206 {
+		t.Errorf("expected status 206, got %d", w.Code)
+	}
+	if w.Body.String() != "0123" {
+		t.Errorf("expected body %q, got %q", "0123", w.Body.String())
+	}
+	if cr := w.Header().Get("Content-Range"); cr != "bytes 0-3/10" {
+		t.Errorf("expected Content-Range bytes 0-3/10, got %s", cr)
+	}
+}
+
+func TestCSVStream(t *testing.T) {
+	rows := [][]string{
+		{"1", "Ada Lovelace", "London"},
+		{"2", "Grace \"Amazing\" Hopper", "New York, NY"},
+		{"3", "Alan Turing", "Wilmslow"},
+	}
+
+	i := 0
+	next := func() ([]string, bool) {
+		if i >= len(rows) {
+			return nil, false
+		}
+		row := rows[i]
+		i++
+		return row, true
+	}
+
+	w := httptest.NewRecorder()
+	if err := CSVStream(w, "people.csv", []string{"id", "name", "city"}, next); err != nil {
+		t.Fatalf("CSVStream() error = %v", err)
+	}
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected text/csv, got %s", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="people.csv"` {
+		t.Errorf("unexpected Content-Disposition: %s", cd)
+	}
+	if !w.Flushed {
+		t.Error("expected response to be flushed")
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected header and 3 rows, got %d records", len(records))
+	}
+	for j, row := range rows {
+		for k, cell := range row {
+			if records[j+1][k] != cell {
+				t.Errorf("row %d col %d: expected %q, got %q", j, k, cell, records[j+1][k])
+			}
+		}
+	}
+}
+
+func TestStreamFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.csv")
+	content := bytes.Repeat([]byte("a,b,c\n"), 20000)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/export.csv", nil)
+
+	var calls int
+	var lastSent, lastTotal int64
+	err := StreamFile(w, r, path, func(sent, total int64) {
+		calls++
+		lastSent, lastTotal = sent, total
+	})
+	if err != nil {
+		t.Fatalf("StreamFile() error = %v", err)
+	}
+
+	if calls < 2 {
+		t.Errorf("expected progress in several chunks, got %d calls", calls)
+	}
+	if lastSent != int64(len(content)) || lastTotal != int64(len(content)) {
+		t.Errorf("expected final progress %d/%d, got %d/%d", len(content), len(content), lastSent, lastTotal)
+	}
+	if !bytes.Equal(w.Body.Bytes(), content) {
+		t.Error("expected body to match file content")
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected text/csv content type, got %s", ct)
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	cancel()
+	w = httptest.NewRecorder()
+	if err := StreamFile(w, r.WithContext(ctx), path, nil); err == nil {
+		t.Error("expected error for cancelled request")
+	}
+}
@@ -1,6 +1,7 @@
 package response
 
 import (
+	"bytes"
 	"fmt"
 	"html/template"
 	"io"
@@ -51,7 +52,13 @@ func (tr *TemplateRenderer) LoadTemplates() error {
 		name := filepath.Base(tmpl)
 		name = strings.TrimSuffix(name, filepath.Ext(name))
 
-		t := template.New(name).Funcs(tr.FuncMap)
+		// The root template must be named after the first parsed file,
+		// otherwise Execute runs an empty template
+		root := filepath.Base(tmpl)
+		if tr.Layout != "" {
+			root = filepath.Base(tr.Layout)
+		}
+		t := template.New(root).Funcs(tr.FuncMap)
 
 		// If layout is set, parse layout first
 		if tr.Layout != "" {
@@ -81,6 +88,33 @@ func (tr *TemplateRenderer) Render(w io.Writer, name string, data interface{}) e
 	return tmpl.Execute(w, data)
 }
 
+// RenderLocalized renders the best localized variant of a template for
+// lang, e.g. "home.fr" for name "home" and lang "fr-CA", falling back to
+// the primary language and then to the base template
+func (tr *TemplateRenderer) RenderLocalized(w io.Writer, name, lang string, data interface{}) error {
+	return tr.Render(w, tr.localizedName(name, lang), data)
+}
+
+// localizedName resolves the loaded template name for name and lang
+func (tr *TemplateRenderer) localizedName(name, lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "" {
+		return name
+	}
+
+	candidates := []string{name + "." + lang}
+	if primary, _, found := strings.Cut(lang, "-"); found {
+		candidates = append(candidates, name+"."+primary)
+	}
+
+	for _, candidate := range candidates {
+		if _, exists := tr.Templates[candidate]; exists {
+			return candidate
+		}
+	}
+	return name
+}
+
 // RenderHTTP renders a template as HTTP response
 func (tr *TemplateRenderer) RenderHTTP(w http.ResponseWriter, code int, name string, data interface{}) error {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -109,3 +143,24 @@ func RenderTemplate(w http.ResponseWriter, code int, name string, data interface
 	}
 	return DefaultTemplateRenderer.RenderHTTP(w, code, name, data)
 }
+
+// RenderError renders an error page with the named template of the default
+// renderer. If the renderer is not initialized or the template is missing
+// or fails, the status text is written as a plain body instead.
+func RenderError(w http.ResponseWriter, code int, name string, data interface{}) error {
+	if DefaultTemplateRenderer != nil {
+		// Render into a buffer first so a failing template can still fall back
+		var buf bytes.Buffer
+		if err := DefaultTemplateRenderer.Render(&buf, name, data); err == nil {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(code)
+			_, err = buf.WriteTo(w)
+			return err
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(code)
+	_, err := io.WriteString(w, http.StatusText(code))
+	return err
+}
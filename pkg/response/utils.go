@@ -15,6 +15,7 @@ var contentTypes = map[string]string{
 	".xml":  "application/xml",
 	".yaml": "application/x-yaml",
 	".yml":  "application/x-yaml",
+	".toml": "application/toml",
 	".html": "text/html",
 	".htm":  "text/html",
 	".txt":  "text/plain",
@@ -3,6 +3,7 @@ package response
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -10,13 +11,19 @@ import (
 	"time"
 )
 
+// ErrResponseCommitted is returned by Reset once the status line or body
+// has been sent
+var ErrResponseCommitted = errors.New("response already committed")
+
 // Writer wraps http.ResponseWriter with additional functionality
 type Writer struct {
 	http.ResponseWriter
-	statusCode int
-	written    bool
-	size       int
-	mu         sync.RWMutex
+	statusCode  int
+	written     bool
+	wroteHeader bool
+	size        int
+	trailers    map[string]bool
+	mu          sync.RWMutex
 }
 
 // NewWriter creates a new Response wrapper
@@ -37,6 +44,7 @@ func (w *Writer) WriteHeader(code int) {
 	}
 
 	w.statusCode = code
+	w.wroteHeader = true
 	w.ResponseWriter.WriteHeader(code)
 }
 
@@ -77,6 +85,66 @@ func (w *Writer) Written() bool {
 	return w.written
 }
 
+// Reset discards the staged headers and status so the response can be
+// rebuilt from scratch, e.g. to replace a partial response with an error
+// page. It returns ErrResponseCommitted once WriteHeader or Write has sent
+// anything to the client.
+func (w *Writer) Reset() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.written || w.wroteHeader {
+		return ErrResponseCommitted
+	}
+
+	header := w.ResponseWriter.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	w.statusCode = 200
+	w.size = 0
+	// The Trailer header went with the rest, so keys must be declared again
+	w.trailers = nil
+	return nil
+}
+
+// DeclareTrailer announces trailer keys in the Trailer header. It must be
+// called before the response is committed, otherwise it returns
+// ErrResponseCommitted.
+func (w *Writer) DeclareTrailer(keys ...string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.written || w.wroteHeader {
+		return ErrResponseCommitted
+	}
+
+	if w.trailers == nil {
+		w.trailers = make(map[string]bool)
+	}
+	for _, key := range keys {
+		key = http.CanonicalHeaderKey(key)
+		if !w.trailers[key] {
+			w.trailers[key] = true
+			w.ResponseWriter.Header().Add("Trailer", key)
+		}
+	}
+	return nil
+}
+
+// SetTrailer sets a trailer value, sent after the body. Undeclared keys
+// are sent using http.TrailerPrefix.
+func (w *Writer) SetTrailer(key, value string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key = http.CanonicalHeaderKey(key)
+	if !w.trailers[key] {
+		key = http.TrailerPrefix + key
+	}
+	w.ResponseWriter.Header().Set(key, value)
+}
+
 // Hijack implements http.Hijacker interface
 func (w *Writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
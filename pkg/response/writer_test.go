@@ -1,6 +1,7 @@
 package response
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -179,3 +180,111 @@ func TestWriterCookie(t *testing.T) {
 		t.Errorf("expected cookie test=value, got %s=%s", cookies[0].Name, cookies[0].Value)
 	}
 }
+
+func TestWriterReset(t *testing.T) {
+	t.Run("before commit", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		writer := NewWriter(w)
+
+		writer.SetHeader("X-Partial", "yes")
+		writer.SetContentType("application/json")
+
+		if err := writer.Reset(); err != nil {
+			t.Fatalf("Reset error: %v", err)
+		}
+
+		if writer.Header().Get("X-Partial") != "" || writer.Header().Get("Content-Type") != "" {
+			t.Errorf("expected staged headers to be cleared, got %v", writer.Header())
+		}
+
+		writer.SetContentType("text/html")
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("error page"))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+		if w.Header().Get("Content-Type") != "text/html" {
+			t.Errorf("expected text/html, got %s", w.Header().Get("Content-Type"))
+		}
+		if w.Body.String() != "error page" {
+			t.Errorf("expected replacement body, got %q", w.Body.String())
+		}
+	})
+
+	t.Run("redeclares trailers", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		writer := NewWriter(w)
+
+		if err := writer.DeclareTrailer("X-Checksum"); err != nil {
+			t.Fatalf("DeclareTrailer error: %v", err)
+		}
+		if err := writer.Reset(); err != nil {
+			t.Fatalf("Reset error: %v", err)
+		}
+		if err := writer.DeclareTrailer("X-Checksum"); err != nil {
+			t.Fatalf("DeclareTrailer error: %v", err)
+		}
+
+		writer.Write([]byte("payload"))
+		writer.SetTrailer("X-Checksum", "abc123")
+
+		result := w.Result()
+		if got := result.Header.Get("Trailer"); got != "X-Checksum" {
+			t.Errorf("expected Trailer header X-Checksum after reset, got %q", got)
+		}
+		if got := result.Trailer.Get("X-Checksum"); got != "abc123" {
+			t.Errorf("expected trailer X-Checksum abc123, got %q", got)
+		}
+	})
+
+	t.Run("after write", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		writer := NewWriter(w)
+
+		writer.Write([]byte("partial"))
+
+		if err := writer.Reset(); !errors.Is(err, ErrResponseCommitted) {
+			t.Errorf("expected ErrResponseCommitted, got %v", err)
+		}
+	})
+
+	t.Run("after WriteHeader", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		writer := NewWriter(w)
+
+		writer.WriteHeader(http.StatusAccepted)
+
+		if err := writer.Reset(); !errors.Is(err, ErrResponseCommitted) {
+			t.Errorf("expected ErrResponseCommitted, got %v", err)
+		}
+	})
+}
+
+func TestWriterTrailers(t *testing.T) {
+	w := httptest.NewRecorder()
+	writer := NewWriter(w)
+
+	if err := writer.DeclareTrailer("X-Checksum"); err != nil {
+		t.Fatalf("DeclareTrailer error: %v", err)
+	}
+
+	writer.Write([]byte("payload"))
+	writer.SetTrailer("X-Checksum", "abc123")
+	writer.SetTrailer("X-Late", "yes")
+
+	result := w.Result()
+	if got := result.Header.Get("Trailer"); got != "X-Checksum" {
+		t.Errorf("expected Trailer header X-Checksum, got %q", got)
+	}
+	if got := result.Trailer.Get("X-Checksum"); got != "abc123" {
+		t.Errorf("expected trailer X-Checksum abc123, got %q", got)
+	}
+	if got := result.Trailer.Get("X-Late"); got != "yes" {
+		t.Errorf("expected undeclared trailer X-Late yes, got %q", got)
+	}
+
+	if err := writer.DeclareTrailer("X-Other"); !errors.Is(err, ErrResponseCommitted) {
+		t.Errorf("expected ErrResponseCommitted after write, got %v", err)
+	}
+}
@@ -1,17 +1,25 @@
 package router
 
 import (
+	"bytes"
+	stdcontext "context"
 	"fmt"
+	"log"
+	"net/http"
+	"net/url"
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/aliwert/go-wolf/pkg/context"
+	"github.com/aliwert/go-wolf/pkg/response"
 )
 
 // RouteConstraint represents advanced parameter constraints
 type RouteConstraint struct {
 	Name    string
+	Rule    string // reported when the constraint fails, e.g. "numeric"
 	Pattern *regexp.Regexp
 	Checker func(string) bool
 }
@@ -26,6 +34,11 @@ type RouteBuilder struct {
 	constraints map[string]RouteConstraint
 	name        string
 	subdomain   string
+	timeout     time.Duration
+	produces    string
+	accepts     reflect.Type
+	cors        *response.CORSMiddleware
+	raw         bool
 }
 
 // NewRouteBuilder creates a new route builder
@@ -72,6 +85,49 @@ func (rb *RouteBuilder) Subdomain(subdomain string) *RouteBuilder {
 	return rb
 }
 
+// Timeout bounds the route's handler to d, replying 503 Service
+// Unavailable if it has not finished in time. Zero disables the timeout.
+func (rb *RouteBuilder) Timeout(d time.Duration) *RouteBuilder {
+	rb.timeout = d
+	return rb
+}
+
+// Accepts declares the request body model of the route, recorded on
+// RouteInfo for documentation. Pointers are recorded as their element type.
+func (rb *RouteBuilder) Accepts(model interface{}) *RouteBuilder {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	rb.accepts = t
+	return rb
+}
+
+// CORS gives the route its own CORS policy, replacing any CORS headers
+// set by group or global middleware. An OPTIONS route answering preflight
+// requests with the same policy is registered alongside it.
+func (rb *RouteBuilder) CORS(cors *response.CORSMiddleware) *RouteBuilder {
+	rb.cors = cors
+	return rb
+}
+
+// DecodeCatchAll controls whether the route's catch-all param is
+// percent-decoded. By default it is, like every other param; file servers
+// that must tell "%2F" from a real slash can pass false to get the raw,
+// still-encoded value instead.
+func (rb *RouteBuilder) DecodeCatchAll(decode bool) *RouteBuilder {
+	rb.raw = !decode
+	return rb
+}
+
+// Produces declares the content type the route's handler responds with.
+// When the router's content type check is enabled, responses with a
+// different Content-Type are reported.
+func (rb *RouteBuilder) Produces(contentType string) *RouteBuilder {
+	rb.produces = contentType
+	return rb
+}
+
 // Where adds parameter constraints
 func (rb *RouteBuilder) Where(param string, constraint interface{}) *RouteBuilder {
 	var rc RouteConstraint
@@ -80,15 +136,22 @@ func (rb *RouteBuilder) Where(param string, constraint interface{}) *RouteBuilde
 	switch c := constraint.(type) {
 	case string:
 		// Regex pattern
+		rc.Rule = "regex"
 		rc.Pattern = regexp.MustCompile(c)
 		rc.Checker = func(value string) bool {
 			return rc.Pattern.MatchString(value)
 		}
 	case func(string) bool:
 		// Custom function
+		rc.Rule = "custom"
+		rc.Checker = c
+	case Constraint:
+		// Combined constraint
+		rc.Rule = "custom"
 		rc.Checker = c
 	case *regexp.Regexp:
 		// Compiled regex
+		rc.Rule = "regex"
 		rc.Pattern = c
 		rc.Checker = func(value string) bool {
 			return rc.Pattern.MatchString(value)
@@ -99,19 +162,28 @@ func (rb *RouteBuilder) Where(param string, constraint interface{}) *RouteBuilde
 	return rb
 }
 
+// whereRule adds a constraint reported under the given rule name
+func (rb *RouteBuilder) whereRule(param, rule string, constraint interface{}) *RouteBuilder {
+	rb.Where(param, constraint)
+	rc := rb.constraints[param]
+	rc.Rule = rule
+	rb.constraints[param] = rc
+	return rb
+}
+
 // WhereNumber constrains parameter to be numeric
 func (rb *RouteBuilder) WhereNumber(param string) *RouteBuilder {
-	return rb.Where(param, IsNumeric)
+	return rb.whereRule(param, "numeric", IsNumeric)
 }
 
 // WhereAlpha constrains parameter to be alphabetic
 func (rb *RouteBuilder) WhereAlpha(param string) *RouteBuilder {
-	return rb.Where(param, IsAlpha)
+	return rb.whereRule(param, "alpha", IsAlpha)
 }
 
 // WhereAlphaNumeric constrains parameter to be alphanumeric
 func (rb *RouteBuilder) WhereAlphaNumeric(param string) *RouteBuilder {
-	return rb.Where(param, IsAlphaNumeric)
+	return rb.whereRule(param, "alphanumeric", IsAlphaNumeric)
 }
 
 // WhereIn constrains parameter to be one of the provided values
@@ -120,7 +192,7 @@ func (rb *RouteBuilder) WhereIn(param string, values ...string) *RouteBuilder {
 	for _, v := range values {
 		valueMap[v] = true
 	}
-	return rb.Where(param, func(value string) bool {
+	return rb.whereRule(param, "in", func(value string) bool {
 		return valueMap[value]
 	})
 }
@@ -128,12 +200,37 @@ func (rb *RouteBuilder) WhereIn(param string, values ...string) *RouteBuilder {
 // WhereUUID constrains parameter to be a valid UUID
 func (rb *RouteBuilder) WhereUUID(param string) *RouteBuilder {
 	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
-	return rb.Where(param, uuidPattern)
+	return rb.whereRule(param, "uuid", uuidPattern)
 }
 
 // WhereSlug constrains parameter to be a URL-friendly slug
 func (rb *RouteBuilder) WhereSlug(param string) *RouteBuilder {
-	return rb.Where(param, IsSlug)
+	return rb.whereRule(param, "slug", IsSlug)
+}
+
+// WhereBase64 constrains parameter to be standard base64
+func (rb *RouteBuilder) WhereBase64(param string) *RouteBuilder {
+	return rb.whereRule(param, "base64", IsBase64)
+}
+
+// WhereLuhn constrains parameter to pass the Luhn checksum, e.g. a card number
+func (rb *RouteBuilder) WhereLuhn(param string) *RouteBuilder {
+	return rb.whereRule(param, "luhn", IsLuhn)
+}
+
+// WhereCountry constrains parameter to an ISO 3166-1 alpha-2 country code
+func (rb *RouteBuilder) WhereCountry(param string) *RouteBuilder {
+	return rb.whereRule(param, "country", IsCountryCode)
+}
+
+// WhereLanguage constrains parameter to an ISO 639-1 language code
+func (rb *RouteBuilder) WhereLanguage(param string) *RouteBuilder {
+	return rb.whereRule(param, "language", IsLanguageCode)
+}
+
+// WhereSemVer constrains parameter to a semantic version
+func (rb *RouteBuilder) WhereSemVer(param string) *RouteBuilder {
+	return rb.whereRule(param, "semver", IsSemVer)
 }
 
 // Build finalizes and registers the route
@@ -141,6 +238,7 @@ func (rb *RouteBuilder) Build() *Route {
 	if rb.method == "" || rb.path == "" || rb.handler == nil {
 		panic("method, path, and handler are required")
 	}
+	rb.path = rb.router.cleanPath(rb.path)
 
 	// Create route info
 	info := &RouteInfo{
@@ -149,23 +247,38 @@ func (rb *RouteBuilder) Build() *Route {
 		Name:       rb.name,
 		Handler:    rb.handler,
 		Middleware: rb.middleware,
+		Timeout:    rb.timeout,
+		Produces:   rb.produces,
+		Accepts:    rb.accepts,
 	}
 
-	// Store constraints in the router
 	if len(rb.constraints) > 0 {
-		if rb.router.constraints == nil {
-			rb.router.constraints = make(map[string]map[string]Constraint)
-		}
-		if rb.router.constraints[rb.path] == nil {
-			rb.router.constraints[rb.path] = make(map[string]Constraint)
-		}
+		info.Constraints = make(map[string]Constraint, len(rb.constraints))
 		for param, constraint := range rb.constraints {
-			rb.router.constraints[rb.path][param] = constraint.Checker
+			info.Constraints[param] = constraint.Checker
 		}
 	}
 
-	// Register the route
+	if rb.cors != nil {
+		info.Handler = corsHandler(rb.cors, info.Handler)
+	}
+
+	// Register the route, then its constraints and catch-all mode, which
+	// registration resets
 	rb.router.registerAdvancedRoute(info)
+	for _, constraint := range rb.constraints {
+		rb.router.setConstraint(rb.method, rb.path, constraint)
+	}
+	if rb.raw {
+		rb.router.setRawCatchAll(rb.method, rb.path)
+	}
+
+	// Answer preflight requests with the route's own policy
+	if rb.cors != nil && rb.method != http.MethodOptions {
+		if err := rb.router.registerPreflight(rb.path, rb.cors, rb.constraints, rb.middleware); err != nil {
+			panic(err)
+		}
+	}
 
 	return &Route{
 		info:   info,
@@ -173,8 +286,205 @@ func (rb *RouteBuilder) Build() *Route {
 	}
 }
 
+// RouteDef declares a route for bulk registration with RegisterRoutes
+type RouteDef struct {
+	Method      string
+	Path        string
+	Name        string
+	Handler     context.HandlerFunc
+	Middleware  []context.HandlerFunc
+	Constraints map[string]Constraint
+}
+
+// RegisterRoutes registers a batch of declared routes. Every definition is
+// validated and checked for conflicts before any is registered, so a bad
+// batch leaves the router unchanged; the first invalid entry is reported
+// by index. Routes registered concurrently with the batch may still
+// conflict with it midway.
+func (r *Router) RegisterRoutes(defs []RouteDef) error {
+	utils := NewRouteUtils()
+	for i, def := range defs {
+		if !utils.IsValidMethod(def.Method) {
+			return fmt.Errorf("route %d: invalid method '%s'", i, def.Method)
+		}
+		if err := utils.ValidatePath(def.Path); err != nil {
+			return fmt.Errorf("route %d: %w", i, err)
+		}
+		if def.Handler == nil {
+			return fmt.Errorf("route %d: handler must not be nil", i)
+		}
+	}
+
+	if err := r.checkConflicts(defs); err != nil {
+		return err
+	}
+
+	for i, def := range defs {
+		def.Path = r.cleanPath(def.Path)
+		if err := r.TryHandle(def.Method, def.Path, def.Handler, def.Middleware...); err != nil {
+			return fmt.Errorf("route %d (%s %s): %w", i, def.Method, def.Path, err)
+		}
+
+		info := &RouteInfo{
+			Method:      def.Method,
+			Path:        def.Path,
+			Name:        def.Name,
+			Handler:     def.Handler,
+			Middleware:  def.Middleware,
+			Constraints: def.Constraints,
+			handle:      def.Handler,
+		}
+		r.storeRouteInfo(info)
+
+		for param, constraint := range def.Constraints {
+			r.setConstraint(def.Method, def.Path, RouteConstraint{Name: param, Rule: "custom", Checker: constraint})
+		}
+	}
+
+	return nil
+}
+
 // registerAdvancedRoute registers a route with advanced features
 func (r *Router) registerAdvancedRoute(info *RouteInfo) {
+	handler := info.Handler
+	if info.Produces != "" {
+		handler = r.producesHandler(info, handler)
+	}
+	if info.Timeout > 0 {
+		handler = timeoutHandler(info.Timeout, handler)
+	}
+	info.handle = handler
+
+	r.storeRouteInfo(info)
+
+	// Register with the underlying router
+	r.Handle(info.Method, info.Path, handler, info.Middleware...)
+}
+
+// checkConflicts registers defs into a scratch router holding the
+// router's current routes, returning the first registration error by index.
+// The tree replaces a route registered twice, so a method and path repeated
+// within defs, and a name repeated within defs or taken by another route,
+// are reported here.
+func (r *Router) checkConflicts(defs []RouteDef) error {
+	scratch := New()
+
+	r.mu.RLock()
+	scratch.normalizePaths = r.normalizePaths
+	named := make(map[string]string, len(r.namedRoutes))
+	for name, info := range r.namedRoutes {
+		named[name] = fmt.Sprintf("existing route %s %s", info.Method, info.Path)
+	}
+	constraints := make(map[string][]RouteConstraint, len(r.constraints))
+	for key, byParam := range r.constraints {
+		for _, rc := range byParam {
+			constraints[key] = append(constraints[key], rc)
+		}
+	}
+	r.mu.RUnlock()
+
+	// Constraints are replayed after each route, as registration resets
+	// them and alternates depend on them
+	err := r.Walk(func(method, path string, handler context.HandlerFunc) error {
+		if err := scratch.TryHandle(method, path, handler); err != nil {
+			return err
+		}
+		for _, rc := range constraints[constraintKey(method, path)] {
+			scratch.setConstraint(method, path, rc)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]int, len(defs))
+	for i, def := range defs {
+		path := scratch.cleanPath(def.Path)
+		key := constraintKey(def.Method, path)
+		if j, ok := seen[key]; ok {
+			return fmt.Errorf("route %d (%s %s): duplicates route %d", i, def.Method, path, j)
+		}
+		seen[key] = i
+
+		if def.Name != "" {
+			if owner, ok := named[def.Name]; ok {
+				return fmt.Errorf("route %d (%s %s): name '%s' is already used by %s", i, def.Method, path, def.Name, owner)
+			}
+			named[def.Name] = fmt.Sprintf("route %d", i)
+		}
+
+		if err := scratch.TryHandle(def.Method, path, def.Handler); err != nil {
+			return fmt.Errorf("route %d (%s %s): %w", i, def.Method, path, err)
+		}
+		for param, constraint := range def.Constraints {
+			scratch.setConstraint(def.Method, path, RouteConstraint{Name: param, Rule: "custom", Checker: constraint})
+		}
+	}
+	return nil
+}
+
+// setRawCatchAll marks the method's route at path as wanting its
+// catch-all percent-encoded
+func (r *Router) setRawCatchAll(method, path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.rawCatchAll == nil {
+		r.rawCatchAll = make(map[string]bool)
+	}
+	r.rawCatchAll[constraintKey(method, path)] = true
+}
+
+// registerPreflight registers an OPTIONS route answering preflight requests
+// for path with cors. Routes on the same path may share a policy; an OPTIONS
+// route already registered with another policy, or by the caller, is a
+// conflict, since registering over it would silently replace it.
+func (r *Router) registerPreflight(path string, cors *response.CORSMiddleware, constraints map[string]RouteConstraint, middleware []context.HandlerFunc) error {
+	r.mu.RLock()
+	owner, ok := r.preflights[path]
+	var existing context.HandlerFunc
+	var route string
+	if root := r.trees[http.MethodOptions]; root != nil {
+		existing, _, route, _ = root.lookup(path)
+	}
+	r.mu.RUnlock()
+
+	if ok {
+		if owner == cors {
+			return nil
+		}
+		return fmt.Errorf("preflight for path '%s' is already answered by another CORS policy", path)
+	}
+	if existing != nil && route == path {
+		return fmt.Errorf("an OPTIONS route is already registered for path '%s', so it cannot answer preflight with the route's CORS policy", path)
+	}
+
+	preflight := corsHandler(cors, func(c *context.Context) error {
+		c.Writer.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	if err := r.TryHandle(http.MethodOptions, path, preflight, middleware...); err != nil {
+		return err
+	}
+	for _, constraint := range constraints {
+		r.setConstraint(http.MethodOptions, path, constraint)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.preflights == nil {
+		r.preflights = make(map[string]*response.CORSMiddleware)
+	}
+	r.preflights[path] = cors
+	return nil
+}
+
+// storeRouteInfo records route metadata for GetRoutes and named URLs
+func (r *Router) storeRouteInfo(info *RouteInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	// Store route info
 	if r.routes == nil {
 		r.routes = make([]*RouteInfo, 0)
@@ -188,48 +498,384 @@ func (r *Router) registerAdvancedRoute(info *RouteInfo) {
 		}
 		r.namedRoutes[info.Name] = info
 	}
+}
 
-	// Register with the underlying router
-	r.Handle(info.Method, info.Path, info.Handler, info.Middleware...)
+// timeoutHandler runs handler with a request deadline of d. The handler
+// writes to a private buffer that is copied to the client only if it
+// finishes in time; past the deadline a 503 is sent instead and the
+// buffered output is dropped. Either way timeoutHandler waits for the
+// handler to return so the pooled context is never used after release, so
+// handlers should watch c.Request.Context() to stop early. A panic in the
+// handler is re-raised on the calling goroutine.
+func timeoutHandler(d time.Duration, handler context.HandlerFunc) context.HandlerFunc {
+	return func(c *context.Context) error {
+		ctx, cancel := stdcontext.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		w := c.Writer
+		buf := &timeoutWriter{header: w.Header().Clone()}
+		c.Writer = response.NewWriter(buf)
+
+		// A panic would otherwise crash the process from the handler's
+		// goroutine, so it is carried back and raised here instead
+		done := make(chan timeoutResult, 1)
+		go func() {
+			var result timeoutResult
+			defer func() {
+				if rec := recover(); rec != nil {
+					result.panicked, result.value = true, rec
+				}
+				done <- result
+			}()
+			result.err = handler(c)
+		}()
+
+		var result timeoutResult
+		timedOut := false
+		select {
+		case result = <-done:
+		case <-ctx.Done():
+			if ctx.Err() == stdcontext.DeadlineExceeded {
+				timedOut = true
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte("Service Unavailable"))
+				w.Flush()
+			}
+			result = <-done
+		}
+
+		c.Writer = w
+		if result.panicked {
+			panic(result.value)
+		}
+		err := result.err
+		if timedOut {
+			return nil
+		}
+		buf.copyTo(w)
+		return err
+	}
+}
+
+// timeoutResult carries a timed handler's outcome back to timeoutHandler
+type timeoutResult struct {
+	err      error
+	panicked bool
+	value    interface{}
+}
+
+// timeoutWriter buffers the response of a handler run by timeoutHandler
+type timeoutWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.header }
+
+func (tw *timeoutWriter) Write(data []byte) (int, error) { return tw.body.Write(data) }
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	if tw.code == 0 {
+		tw.code = code
+	}
+}
+
+// copyTo replays the buffered headers, status and body onto w
+func (tw *timeoutWriter) copyTo(w *response.Writer) {
+	header := w.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, values := range tw.header {
+		header[key] = values
+	}
+
+	if tw.code != 0 {
+		w.WriteHeader(tw.code)
+	}
+	if tw.body.Len() > 0 {
+		w.Write(tw.body.Bytes())
+	}
+}
+
+// corsHandler applies a route-scoped CORS policy before handler, dropping
+// CORS headers staged by outer middleware first. A request from an origin
+// the policy rejects gets no CORS headers at all.
+func corsHandler(cors *response.CORSMiddleware, handler context.HandlerFunc) context.HandlerFunc {
+	return func(c *context.Context) error {
+		header := c.Writer.Header()
+		for _, key := range []string{
+			"Access-Control-Allow-Origin",
+			"Access-Control-Allow-Methods",
+			"Access-Control-Allow-Headers",
+			"Access-Control-Allow-Credentials",
+			"Access-Control-Expose-Headers",
+			"Access-Control-Max-Age",
+		} {
+			header.Del(key)
+		}
+
+		if cors.IsOriginAllowed(c.Request.Header.Get("Origin")) {
+			cors.Wrap(c.Writer, c.Request)
+		}
+		return handler(c)
+	}
+}
+
+// ContentTypeCheck controls how responses that don't match a route's
+// declared Produces type are reported
+type ContentTypeCheck int
+
+const (
+	// ContentTypeCheckOff skips the check (the default)
+	ContentTypeCheckOff ContentTypeCheck = iota
+	// ContentTypeCheckWarn logs mismatches, for development
+	ContentTypeCheckWarn
+	// ContentTypeCheckStrict panics on mismatches, for tests
+	ContentTypeCheckStrict
+)
+
+// SetContentTypeCheck sets how Produces mismatches are reported
+func (r *Router) SetContentTypeCheck(mode ContentTypeCheck) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.contentTypeCheck = mode
+}
+
+// producesHandler checks the Content-Type written by handler against the
+// route's declared Produces type
+func (r *Router) producesHandler(info *RouteInfo, handler context.HandlerFunc) context.HandlerFunc {
+	declared := mediaType(info.Produces)
+	return func(c *context.Context) error {
+		err := handler(c)
+
+		r.mu.RLock()
+		mode := r.contentTypeCheck
+		r.mu.RUnlock()
+		if mode == ContentTypeCheckOff {
+			return err
+		}
+
+		actual := c.Writer.Header().Get("Content-Type")
+		if actual == "" || mediaType(actual) == declared {
+			return err
+		}
+
+		message := fmt.Sprintf("route %s %s declared Produces(%q) but wrote Content-Type %q",
+			info.Method, info.Path, info.Produces, actual)
+		if mode == ContentTypeCheckStrict {
+			panic(message)
+		}
+		log.Print("[WARN] " + message)
+		return err
+	}
+}
+
+// mediaType returns the lower-cased media type without parameters
+func mediaType(contentType string) string {
+	mt, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// Alias registers oldPath for method to permanently redirect to newPath.
+// Params captured by oldPath are substituted into newPath by name and the
+// query string is preserved, so "/u/:id" can alias "/users/:id".
+func (r *Router) Alias(oldPath, newPath string, method string) {
+	utils := NewRouteUtils()
+	oldPattern := utils.ParseRoutePattern(oldPath)
+	newPattern := utils.ParseRoutePattern(newPath)
+
+	captured := make(map[string]bool)
+	for _, name := range append(oldPattern.Params, oldPattern.Wildcards...) {
+		captured[name] = true
+	}
+	for _, name := range append(newPattern.Params, newPattern.Wildcards...) {
+		if !captured[name] {
+			panic("alias target '" + newPath + "' uses param '" + name + "' not captured by '" + oldPath + "'")
+		}
+	}
+
+	segments := strings.Split(newPath, "/")
+
+	r.Handle(method, oldPath, func(c *context.Context) error {
+		parts := make([]string, len(segments))
+		for i, segment := range segments {
+			switch {
+			case strings.HasPrefix(segment, ":"):
+				parts[i] = url.PathEscape(c.Param(paramName(segment[1:])))
+			case strings.HasPrefix(segment, "*"):
+				parts[i] = strings.TrimPrefix(c.Param(paramName(segment[1:])), "/")
+			default:
+				parts[i] = segment
+			}
+		}
+
+		target := strings.Join(parts, "/")
+		if c.Request.URL.RawQuery != "" {
+			target += "?" + c.Request.URL.RawQuery
+		}
+
+		http.Redirect(c.Writer, c.Request, target, http.StatusMovedPermanently)
+		return nil
+	})
 }
 
 // URL generates a URL for a named route
 func (r *Router) URL(name string, params map[string]string) (string, error) {
-	if r.namedRoutes == nil {
-		return "", fmt.Errorf("no named routes registered")
+	route, err := r.namedRoute(name)
+	if err != nil {
+		return "", err
 	}
 
-	route, exists := r.namedRoutes[name]
-	if !exists {
-		return "", fmt.Errorf("route '%s' not found", name)
+	// Params missing from params are left as placeholders
+	segments := strings.Split(route.Path, "/")
+	for i, segment := range segments {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		value, ok := params[paramName(segment[1:])]
+		if !ok {
+			continue
+		}
+		if segment[0] == '*' {
+			value = strings.TrimPrefix(value, "/")
+		}
+		segments[i] = value
 	}
 
-	path := route.Path
-	for key, value := range params {
-		placeholder := ":" + key
-		path = strings.Replace(path, placeholder, value, -1)
+	return strings.Join(segments, "/"), nil
+}
+
+// URLStrict generates a URL for a named route, rejecting params that
+// would not satisfy the route's constraints
+func (r *Router) URLStrict(name string, params map[string]string) (string, error) {
+	path, err := r.URL(name, params)
+	if err != nil {
+		return "", err
+	}
+
+	route, _ := r.namedRoute(name)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if err := NewConstraintValidator().ValidateParams(params, route.Constraints); err != nil {
+		return "", err
 	}
 
 	return path, nil
 }
 
-// GetRoutes returns all registered routes
+// namedRoute returns the route registered under name
+func (r *Router) namedRoute(name string) (*RouteInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.namedRoutes == nil {
+		return nil, fmt.Errorf("no named routes registered")
+	}
+
+	route, exists := r.namedRoutes[name]
+	if !exists {
+		return nil, fmt.Errorf("route '%s' not found", name)
+	}
+	return route, nil
+}
+
+// GetRoutes returns a copy of all registered routes
 func (r *Router) GetRoutes() []*RouteInfo {
-	return r.routes
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*RouteInfo(nil), r.routes...)
 }
 
-// GetNamedRoutes returns all named routes
+// GetNamedRoutes returns a copy of the named routes
 func (r *Router) GetNamedRoutes() map[string]*RouteInfo {
-	return r.namedRoutes
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.namedRoutes == nil {
+		return nil
+	}
+	named := make(map[string]*RouteInfo, len(r.namedRoutes))
+	for name, info := range r.namedRoutes {
+		named[name] = info
+	}
+	return named
 }
 
 // SetNotFoundHandler sets a custom 404 handler
 func (r *Router) SetNotFoundHandler(handler context.HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.notFoundHandler = handler
 }
 
+// SetNotFoundHandlerForPrefix sets a 404 handler for paths under prefix,
+// e.g. a JSON 404 for "/api" next to an HTML one for the rest of the
+// site. The longest matching prefix wins over the global 404 handler;
+// a nil handler removes the prefix.
+func (r *Router) SetNotFoundHandlerForPrefix(prefix string, handler context.HandlerFunc) {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if handler == nil {
+		delete(r.prefixNotFound, prefix)
+		return
+	}
+	if r.prefixNotFound == nil {
+		r.prefixNotFound = make(map[string]context.HandlerFunc)
+	}
+	r.prefixNotFound[prefix] = handler
+}
+
+// notFoundHandlerFor returns the 404 handler for path: the one registered
+// for its longest matching prefix, or the global one. The caller must
+// hold r.mu.
+func (r *Router) notFoundHandlerFor(path string) context.HandlerFunc {
+	handler := r.notFoundHandler
+	longest := -1
+	for prefix, h := range r.prefixNotFound {
+		if len(prefix) > longest && (path == prefix || strings.HasPrefix(path, prefix+"/")) {
+			handler = h
+			longest = len(prefix)
+		}
+	}
+	return handler
+}
+
+// SetDescribeOptions makes automatic OPTIONS responses carry a JSON
+// OptionsDescription instead of an empty 204
+func (r *Router) SetDescribeOptions(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.describeOptions = enabled
+}
+
+// SetNormalizePaths enables cleaning registered and requested paths with
+// RouteUtils.NormalizePath. Enable it before registering routes.
+func (r *Router) SetNormalizePaths(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.normalizePaths = enabled
+}
+
+// SetConstraintFailStatus sets the status used when a route matches but a
+// param constraint fails: http.StatusNotFound (the default) or
+// http.StatusBadRequest, which also reports the failing param
+func (r *Router) SetConstraintFailStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.constraintFailStatus = status
+}
+
 // SetMethodNotAllowedHandler sets a custom 405 handler
 func (r *Router) SetMethodNotAllowedHandler(handler context.HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.methodNotAllowedHandler = handler
 }
 
@@ -1,11 +1,19 @@
 package router
 
 import (
+	"bytes"
+	"encoding/json"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aliwert/go-wolf/pkg/context"
+	"github.com/aliwert/go-wolf/pkg/response"
 )
 
 func TestRouteBuilder(t *testing.T) {
@@ -467,3 +475,777 @@ func BenchmarkConstraintBuilder(b *testing.B) {
 		constraint("test")
 	}
 }
+
+func TestRouterURLStrict(t *testing.T) {
+	router := New()
+
+	router.NewRoute().
+		Method("GET").
+		Path("/users/:id").
+		Handler(func(c *context.Context) error { return nil }).
+		Name("users.show").
+		WhereNumber("id").
+		Build()
+
+	url, err := router.URLStrict("users.show", map[string]string{"id": "42"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if url != "/users/42" {
+		t.Errorf("Expected '/users/42', got '%s'", url)
+	}
+
+	if _, err := router.URLStrict("users.show", map[string]string{"id": "abc"}); err == nil {
+		t.Error("Expected error for non-numeric id")
+	}
+
+	// URL keeps its lenient behavior
+	if url, _ := router.URL("users.show", map[string]string{"id": "abc"}); url != "/users/abc" {
+		t.Errorf("Expected '/users/abc', got '%s'", url)
+	}
+
+	// Bounded catch-alls are filled in by name, without their options
+	router.NewRoute().
+		Method("GET").
+		Path("/files/*path{maxdepth=2}").
+		Handler(func(c *context.Context) error { return nil }).
+		Name("files").
+		Build()
+	if url, _ := router.URL("files", map[string]string{"path": "/docs/a.txt"}); url != "/files/docs/a.txt" {
+		t.Errorf("Expected '/files/docs/a.txt', got '%s'", url)
+	}
+}
+
+func TestWhereBase64(t *testing.T) {
+	router := New()
+
+	router.NewRoute().
+		Method("GET").
+		Path("/tokens/:token").
+		Handler(func(c *context.Context) error { return nil }).
+		Name("tokens.show").
+		WhereBase64("token").
+		Build()
+
+	if _, err := router.URLStrict("tokens.show", map[string]string{"token": "aGVsbG8="}); err != nil {
+		t.Errorf("Expected no error for valid base64, got %v", err)
+	}
+
+	if _, err := router.URLStrict("tokens.show", map[string]string{"token": "not base64!"}); err == nil {
+		t.Error("Expected error for invalid base64")
+	}
+}
+
+func TestWhereLuhn(t *testing.T) {
+	router := New()
+
+	router.NewRoute().
+		Method("POST").
+		Path("/checkout/:card").
+		Handler(func(c *context.Context) error { return nil }).
+		Name("checkout").
+		WhereLuhn("card").
+		Build()
+
+	if _, err := router.URLStrict("checkout", map[string]string{"card": "4111111111111111"}); err != nil {
+		t.Errorf("Expected no error for valid card number, got %v", err)
+	}
+
+	if _, err := router.URLStrict("checkout", map[string]string{"card": "4111111111111112"}); err == nil {
+		t.Error("Expected error for invalid card number")
+	}
+}
+
+func TestWhereSemVer(t *testing.T) {
+	router := New()
+
+	router.NewRoute().
+		Method("GET").
+		Path("/releases/:version").
+		Handler(func(c *context.Context) error { return nil }).
+		Name("release").
+		WhereSemVer("version").
+		Build()
+
+	for _, version := range []string{"1.2.3", "1.0.0-rc.1+build5"} {
+		if _, err := router.URLStrict("release", map[string]string{"version": version}); err != nil {
+			t.Errorf("Expected no error for %s, got %v", version, err)
+		}
+	}
+
+	for _, version := range []string{"1.2", "v1.2.3"} {
+		if _, err := router.URLStrict("release", map[string]string{"version": version}); err == nil {
+			t.Errorf("Expected error for %s", version)
+		}
+	}
+}
+
+func TestRouteTimeout(t *testing.T) {
+	router := New()
+
+	slow := func(c *context.Context) error {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			return c.String(http.StatusOK, "done")
+		case <-c.Request.Context().Done():
+			return c.Request.Context().Err()
+		}
+	}
+
+	router.NewRoute().Method("GET").Path("/short").Handler(slow).Timeout(10 * time.Millisecond).Build()
+	router.NewRoute().Method("GET").Path("/generous").Handler(slow).Timeout(time.Second).Build()
+	router.NewRoute().Method("GET").Path("/none").Handler(slow).Timeout(0).Build()
+
+	// Ignores the deadline and writes after it; none of its output may
+	// reach the client
+	stubborn := func(c *context.Context) error {
+		c.SetHeader("X-Late", "1")
+		time.Sleep(30 * time.Millisecond)
+		return c.String(http.StatusOK, "late")
+	}
+	router.NewRoute().Method("GET").Path("/stubborn").Handler(stubborn).Timeout(10 * time.Millisecond).Build()
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/short", http.StatusServiceUnavailable},
+		{"/generous", http.StatusOK},
+		{"/none", http.StatusOK},
+		{"/stubborn", http.StatusServiceUnavailable},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		c := context.Acquire()
+		c.Reset(w, req)
+
+		router.ServeHTTP(w, req, c)
+
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.path, test.status, w.Code)
+		}
+		if test.status == http.StatusServiceUnavailable {
+			if body := w.Body.String(); body != "Service Unavailable" {
+				t.Errorf("%s: expected only the timeout body, got %q", test.path, body)
+			}
+			if w.Header().Get("X-Late") != "" {
+				t.Errorf("%s: header from timed-out handler leaked", test.path)
+			}
+		}
+
+		context.Release(c)
+	}
+}
+
+func TestRouteTimeoutPanic(t *testing.T) {
+	router := New()
+	router.NewRoute().Method("GET").Path("/boom").Handler(func(c *context.Context) error {
+		panic("boom")
+	}).Timeout(time.Second).Build()
+
+	req := httptest.NewRequest("GET", "/boom", nil)
+	w := httptest.NewRecorder()
+	c := context.Acquire()
+	c.Reset(w, req)
+	defer context.Release(c)
+
+	defer func() {
+		if rec := recover(); rec != "boom" {
+			t.Errorf("Expected the handler panic on the serving goroutine, got %v", rec)
+		}
+	}()
+	router.ServeHTTP(w, req, c)
+	t.Error("Expected ServeHTTP to panic")
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	handler := func(c *context.Context) error {
+		return c.String(http.StatusOK, c.Param("id"))
+	}
+
+	t.Run("ValidBatch", func(t *testing.T) {
+		router := New()
+		err := router.RegisterRoutes([]RouteDef{
+			{Method: "GET", Path: "/users", Name: "users.index", Handler: handler},
+			{Method: "GET", Path: "/users/:id", Name: "users.show", Handler: handler,
+				Constraints: map[string]Constraint{"id": IsNumeric}},
+			{Method: "POST", Path: "/users", Handler: handler},
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if len(router.GetRoutes()) != 3 {
+			t.Errorf("Expected 3 routes, got %d", len(router.GetRoutes()))
+		}
+
+		if _, err := router.URLStrict("users.show", map[string]string{"id": "abc"}); err == nil {
+			t.Error("Expected constraint error for non-numeric id")
+		}
+
+		req := httptest.NewRequest("GET", "/users/7", nil)
+		w := httptest.NewRecorder()
+		c := context.Acquire()
+		c.Reset(w, req)
+		router.ServeHTTP(w, req, c)
+		context.Release(c)
+
+		if w.Body.String() != "7" {
+			t.Errorf("Expected body '7', got '%s'", w.Body.String())
+		}
+	})
+
+	t.Run("InvalidMethod", func(t *testing.T) {
+		router := New()
+		err := router.RegisterRoutes([]RouteDef{
+			{Method: "GET", Path: "/a", Handler: handler},
+			{Method: "FETCH", Path: "/b", Handler: handler},
+		})
+		if err == nil {
+			t.Fatal("Expected error for invalid method")
+		}
+		if !strings.Contains(err.Error(), "route 1") {
+			t.Errorf("Expected error to name route 1, got %v", err)
+		}
+		if len(router.GetRoutes()) != 0 {
+			t.Errorf("Expected no routes registered, got %d", len(router.GetRoutes()))
+		}
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		router := New()
+		err := router.RegisterRoutes([]RouteDef{
+			{Method: "GET", Path: "/files/*path", Handler: handler},
+			{Method: "GET", Path: "/files/:name", Handler: handler},
+		})
+		if err == nil || !strings.Contains(err.Error(), "route 1") {
+			t.Errorf("Expected conflict error for route 1, got %v", err)
+		}
+		if len(router.GetRoutes()) != 0 {
+			t.Errorf("Expected no routes registered, got %d", len(router.GetRoutes()))
+		}
+		if router.AllowedMethods("/files/a") != nil {
+			t.Error("Expected the conflicting batch to leave the tree untouched")
+		}
+	})
+
+	t.Run("ConflictWithExisting", func(t *testing.T) {
+		router := New()
+		router.Handle("GET", "/files/*path", handler)
+		err := router.RegisterRoutes([]RouteDef{
+			{Method: "GET", Path: "/a", Handler: handler},
+			{Method: "GET", Path: "/files/:name", Handler: handler},
+		})
+		if err == nil || !strings.Contains(err.Error(), "route 1") {
+			t.Errorf("Expected conflict error for route 1, got %v", err)
+		}
+		if router.AllowedMethods("/a") != nil {
+			t.Error("Expected /a not to be registered")
+		}
+	})
+
+	t.Run("DuplicateInBatch", func(t *testing.T) {
+		router := New()
+		err := router.RegisterRoutes([]RouteDef{
+			{Method: "GET", Path: "/a", Handler: handler},
+			{Method: "POST", Path: "/a", Handler: handler},
+			{Method: "GET", Path: "/a", Handler: handler},
+		})
+		if err == nil || !strings.Contains(err.Error(), "route 2") || !strings.Contains(err.Error(), "route 0") {
+			t.Errorf("Expected route 2 to be reported as a duplicate of route 0, got %v", err)
+		}
+		if len(router.GetRoutes()) != 0 {
+			t.Errorf("Expected no routes registered, got %d", len(router.GetRoutes()))
+		}
+	})
+
+	t.Run("DuplicateName", func(t *testing.T) {
+		router := New()
+		err := router.RegisterRoutes([]RouteDef{
+			{Method: "GET", Path: "/a", Name: "a", Handler: handler},
+			{Method: "GET", Path: "/b", Name: "a", Handler: handler},
+		})
+		if err == nil || !strings.Contains(err.Error(), "route 1") {
+			t.Errorf("Expected a duplicate name error for route 1, got %v", err)
+		}
+
+		router.HandleRoute("GET", "/c", handler).Name("c")
+		err = router.RegisterRoutes([]RouteDef{
+			{Method: "GET", Path: "/d", Name: "c", Handler: handler},
+		})
+		if err == nil || !strings.Contains(err.Error(), "GET /c") {
+			t.Errorf("Expected a name conflict with GET /c, got %v", err)
+		}
+		if router.AllowedMethods("/d") != nil {
+			t.Error("Expected /d not to be registered")
+		}
+	})
+}
+
+func TestRouterAlias(t *testing.T) {
+	router := New()
+	router.Handle("GET", "/users/:id", func(c *context.Context) error {
+		return c.String(http.StatusOK, c.Param("id"))
+	})
+	router.Alias("/u/:id", "/users/:id", "GET")
+	router.Alias("/people", "/users", "GET")
+	router.Alias("/assets/*file", "/static/*file", "GET")
+
+	tests := []struct {
+		path     string
+		location string
+	}{
+		{"/people", "/users"},
+		{"/u/42", "/users/42"},
+		{"/u/42?tab=posts", "/users/42?tab=posts"},
+		{"/assets/css/main.css", "/static/css/main.css"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		c := context.Acquire()
+		c.Reset(w, req)
+
+		router.ServeHTTP(w, req, c)
+
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("%s: expected status 301, got %d", test.path, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != test.location {
+			t.Errorf("%s: expected Location '%s', got '%s'", test.path, test.location, location)
+		}
+
+		context.Release(c)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for alias target with uncaptured param")
+		}
+	}()
+	router.Alias("/x", "/users/:id", "GET")
+}
+
+func TestConstraintFailStatus(t *testing.T) {
+	register := func(router *Router) {
+		router.NewRoute().
+			Method("GET").
+			Path("/users/:id").
+			Handler(func(c *context.Context) error {
+				return c.String(http.StatusOK, c.Param("id"))
+			}).
+			WhereNumber("id").
+			Build()
+	}
+
+	serve := func(router *Router, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		c := context.Acquire()
+		c.Reset(w, req)
+		router.ServeHTTP(w, req, c)
+		context.Release(c)
+		return w
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		router := New()
+		register(router)
+
+		if w := serve(router, "/users/42"); w.Code != http.StatusOK || w.Body.String() != "42" {
+			t.Errorf("Expected 200 '42', got %d '%s'", w.Code, w.Body.String())
+		}
+
+		w := serve(router, "/users/abc")
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+		if strings.Contains(w.Body.String(), "id") {
+			t.Errorf("Expected opaque 404 body, got '%s'", w.Body.String())
+		}
+	})
+
+	t.Run("BadRequest", func(t *testing.T) {
+		router := NewWithOptions(RouterOptions{ConstraintFailStatus: http.StatusBadRequest})
+		register(router)
+
+		w := serve(router, "/users/abc")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", w.Code)
+		}
+		if body := w.Body.String(); !strings.Contains(body, "'id'") || !strings.Contains(body, "'numeric'") {
+			t.Errorf("Expected body to name id and numeric, got '%s'", body)
+		}
+	})
+
+	t.Run("FirstParamReported", func(t *testing.T) {
+		router := NewWithOptions(RouterOptions{ConstraintFailStatus: http.StatusBadRequest})
+		router.NewRoute().Method("GET").Path("/orgs/:org/repos/:repo").
+			Handler(func(c *context.Context) error { return nil }).
+			WhereAlpha("org").
+			WhereNumber("repo").
+			Build()
+
+		// Both params fail; the report must not depend on map order
+		for i := 0; i < 20; i++ {
+			body := serve(router, "/orgs/42/repos/abc").Body.String()
+			if !strings.Contains(body, "'org'") {
+				t.Fatalf("Expected the first failing param in route order, got '%s'", body)
+			}
+		}
+	})
+}
+
+func TestProducesCheck(t *testing.T) {
+	router := New()
+
+	router.NewRoute().Method("GET").Path("/json").Produces("application/json").
+		Handler(func(c *context.Context) error {
+			return c.JSON(http.StatusOK, map[string]string{"ok": "yes"})
+		}).Build()
+	router.NewRoute().Method("GET").Path("/html").Produces("application/json").
+		Handler(func(c *context.Context) error {
+			return c.String(http.StatusOK, "<p>oops</p>", response.WithContentType("text/html"))
+		}).Build()
+
+	serve := func(path string) {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		c := context.Acquire()
+		defer context.Release(c)
+		c.Reset(w, req)
+		router.ServeHTTP(w, req, c)
+	}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	router.SetContentTypeCheck(ContentTypeCheckWarn)
+
+	serve("/json")
+	if logs.Len() != 0 {
+		t.Errorf("Expected no warning for matching content type, got %q", logs.String())
+	}
+
+	serve("/html")
+	if !strings.Contains(logs.String(), "text/html") {
+		t.Errorf("Expected mismatch warning, got %q", logs.String())
+	}
+
+	router.SetContentTypeCheck(ContentTypeCheckStrict)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected panic in strict mode")
+			}
+		}()
+		serve("/html")
+	}()
+
+	logs.Reset()
+	router.SetContentTypeCheck(ContentTypeCheckOff)
+	serve("/html")
+	if logs.Len() != 0 {
+		t.Errorf("Expected no warning with check off, got %q", logs.String())
+	}
+}
+
+func TestRouteAccepts(t *testing.T) {
+	type CreateUser struct {
+		Name string `json:"name"`
+	}
+
+	router := New()
+	router.NewRoute().
+		Method("POST").
+		Path("/users").
+		Handler(func(c *context.Context) error { return nil }).
+		Accepts(&CreateUser{}).
+		Build()
+
+	routes := router.GetRoutes()
+	if len(routes) != 1 {
+		t.Fatalf("Expected 1 route, got %d", len(routes))
+	}
+	if routes[0].Accepts != reflect.TypeOf(CreateUser{}) {
+		t.Errorf("Expected Accepts to be CreateUser, got %v", routes[0].Accepts)
+	}
+}
+
+func TestRouteCORS(t *testing.T) {
+	router := New()
+
+	// Stands in for a restrictive global policy
+	restrict := func(c *context.Context) error {
+		c.Writer.Header().Set("Access-Control-Allow-Origin", "https://app.example.com")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST")
+		return c.Next()
+	}
+
+	public := response.NewCORSMiddleware()
+	public.AllowedMethods = []string{"GET"}
+
+	router.NewRoute().Method("GET").Path("/public").Middleware(restrict).CORS(public).
+		Handler(func(c *context.Context) error {
+			return c.String(http.StatusOK, "public")
+		}).Build()
+
+	serve := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/public", nil)
+		req.Header.Set("Origin", "https://other.example.org")
+		w := httptest.NewRecorder()
+		c := context.Acquire()
+		defer context.Release(c)
+		c.Reset(w, req)
+		router.ServeHTTP(w, req, c)
+		return w
+	}
+
+	w := serve("GET")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://other.example.org" {
+		t.Errorf("Expected route policy to allow origin, got %q", got)
+	}
+
+	w = serve("OPTIONS")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected preflight status 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET" {
+		t.Errorf("Expected preflight to use route policy, got %q", got)
+	}
+}
+
+func TestRouteCORSRejectedOrigin(t *testing.T) {
+	router := New()
+
+	// Stands in for a permissive global policy
+	permissive := func(c *context.Context) error {
+		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Total")
+		return c.Next()
+	}
+
+	private := response.NewCORSMiddleware()
+	private.SetAllowedOrigins("https://app.example.com")
+
+	router.NewRoute().Method("GET").Path("/private").Middleware(permissive).CORS(private).
+		Handler(func(c *context.Context) error {
+			return c.String(http.StatusOK, "private")
+		}).Build()
+
+	for _, method := range []string{"GET", "OPTIONS"} {
+		req := httptest.NewRequest(method, "/private", nil)
+		req.Header.Set("Origin", "https://evil.example.org")
+		w := httptest.NewRecorder()
+		c := context.Acquire()
+		c.Reset(w, req)
+		router.ServeHTTP(w, req, c)
+		context.Release(c)
+
+		for key := range w.Header() {
+			if strings.HasPrefix(key, "Access-Control-") {
+				t.Errorf("%s: expected no CORS headers for a rejected origin, got %s: %q", method, key, w.Header().Get(key))
+			}
+		}
+	}
+}
+
+func TestRouteCORSPreflightConflict(t *testing.T) {
+	router := New()
+	handler := func(c *context.Context) error { return nil }
+
+	shared := response.NewCORSMiddleware()
+	router.NewRoute().Method("GET").Path("/items").CORS(shared).Handler(handler).Build()
+	// The same policy may answer preflight for several methods
+	router.NewRoute().Method("POST").Path("/items").CORS(shared).Handler(handler).Build()
+
+	other := response.NewCORSMiddleware()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected a different CORS policy on the same path to panic")
+			}
+		}()
+		router.NewRoute().Method("PUT").Path("/items").CORS(other).Handler(handler).Build()
+	}()
+
+	router.Handle("OPTIONS", "/own", handler)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected a CORS route over an existing OPTIONS route to panic")
+			}
+		}()
+		router.NewRoute().Method("GET").Path("/own").CORS(shared).Handler(handler).Build()
+	}()
+}
+
+func TestDescribeOptions(t *testing.T) {
+	router := New()
+	router.SetDescribeOptions(true)
+
+	handler := func(c *context.Context) error { return nil }
+	router.NewRoute().Method("GET").Path("/users/:id").Handler(handler).WhereNumber("id").Build()
+	router.NewRoute().Method("PUT").Path("/users/:id").Handler(handler).WhereNumber("id").Build()
+
+	req := httptest.NewRequest("OPTIONS", "/users/42", nil)
+	w := httptest.NewRecorder()
+	c := context.Acquire()
+	defer context.Release(c)
+	c.Reset(w, req)
+	router.ServeHTTP(w, req, c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var desc OptionsDescription
+	if err := json.Unmarshal(w.Body.Bytes(), &desc); err != nil {
+		t.Fatalf("Failed to decode OPTIONS body: %v", err)
+	}
+
+	if strings.Join(desc.Allow, ",") != "GET,HEAD,OPTIONS,PUT" {
+		t.Errorf("Expected allow GET,HEAD,OPTIONS,PUT, got %v", desc.Allow)
+	}
+	if len(desc.Routes) != 2 {
+		t.Fatalf("Expected 2 routes, got %d", len(desc.Routes))
+	}
+	for _, route := range desc.Routes {
+		if route.Path != "/users/:id" {
+			t.Errorf("Expected path /users/:id, got %s", route.Path)
+		}
+		if len(route.Params) != 1 || route.Params[0].Name != "id" || route.Params[0].Constraint != "numeric" {
+			t.Errorf("Expected numeric id param for %s, got %+v", route.Method, route.Params)
+		}
+	}
+
+	// Values failing the constraint match no route
+	req = httptest.NewRequest("OPTIONS", "/users/abc", nil)
+	w = httptest.NewRecorder()
+	c.Reset(w, req)
+	router.ServeHTTP(w, req, c)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unconstrained value, got %d", w.Code)
+	}
+
+	// A bounded catch-all is described by name only
+	router.NewRoute().Method("GET").Path("/files/*path{maxdepth=2}").Handler(handler).Build()
+	req = httptest.NewRequest("OPTIONS", "/files/a/b", nil)
+	w = httptest.NewRecorder()
+	c.Reset(w, req)
+	router.ServeHTTP(w, req, c)
+
+	desc = OptionsDescription{}
+	if err := json.Unmarshal(w.Body.Bytes(), &desc); err != nil {
+		t.Fatalf("Failed to decode OPTIONS body: %v", err)
+	}
+	if len(desc.Routes) != 1 || len(desc.Routes[0].Params) != 1 || desc.Routes[0].Params[0].Name != "path" {
+		t.Errorf("Expected one catch-all param named path, got %+v", desc.Routes)
+	}
+}
+
+func TestRouteSetMiddleware(t *testing.T) {
+	router := New()
+
+	tag := func(value string) context.HandlerFunc {
+		return func(c *context.Context) error {
+			c.SetHeader("X-Policy", value)
+			return c.Next()
+		}
+	}
+
+	route := router.NewRoute().Method("GET").Path("/reports/:id").Middleware(tag("old")).
+		Handler(func(c *context.Context) error {
+			return c.String(http.StatusOK, c.Param("id"))
+		}).Build()
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/reports/7", nil)
+		w := httptest.NewRecorder()
+		c := context.Acquire()
+		defer context.Release(c)
+		c.Reset(w, req)
+		router.ServeHTTP(w, req, c)
+		return w
+	}
+
+	if got := serve().Header().Get("X-Policy"); got != "old" {
+		t.Errorf("Expected original middleware to run, got %q", got)
+	}
+	if len(route.GetMiddleware()) != 1 {
+		t.Errorf("Expected 1 middleware, got %d", len(route.GetMiddleware()))
+	}
+
+	route.SetMiddleware(tag("new"))
+
+	w := serve()
+	if got := w.Header().Get("X-Policy"); got != "new" {
+		t.Errorf("Expected replacement middleware to run, got %q", got)
+	}
+	if w.Body.String() != "7" {
+		t.Errorf("Expected handler to still run, got %q", w.Body.String())
+	}
+
+	route.SetMiddleware()
+	if got := serve().Header().Get("X-Policy"); got != "" {
+		t.Errorf("Expected no middleware, got %q", got)
+	}
+}
+
+func TestDecodeCatchAll(t *testing.T) {
+	router := New()
+
+	echo := func(c *context.Context) error {
+		return c.String(http.StatusOK, c.Param("path"))
+	}
+	router.NewRoute().Method("GET").Path("/files/*path").Handler(echo).DecodeCatchAll(false).Build()
+	router.NewRoute().Method("GET").Path("/static/*path").Handler(echo).Build()
+	router.NewRoute().Method("GET").Path("/proxy/:service/*path").Handler(echo).DecodeCatchAll(true).Build()
+	router.NewRoute().Method("POST").Path("/proxy/:service/*path").Handler(echo).DecodeCatchAll(false).Build()
+	router.NewRoute().Method("GET").Path("/dirs/:dir/*path").Handler(func(c *context.Context) error {
+		return c.String(http.StatusOK, c.Param("dir")+"|"+c.Param("path"))
+	}).DecodeCatchAll(false).Build()
+
+	normalized := New()
+	normalized.SetNormalizePaths(true)
+	normalized.NewRoute().Method("GET").Path("/files/*path").Handler(echo).DecodeCatchAll(false).Build()
+
+	tests := []struct {
+		router   *Router
+		method   string
+		url      string
+		expected string
+	}{
+		{router, "GET", "/files/docs/a%2Fb.txt", "/docs/a%2Fb.txt"},
+		{router, "GET", "/files/plain/name.txt", "/plain/name.txt"},
+		{router, "GET", "/static/my%20file.txt", "/my file.txt"},
+		{router, "GET", "/static/a%2Fb.txt", "/a/b.txt"},
+		{router, "GET", "/proxy/users/v1%2Flist", "/v1/list"},
+		{router, "POST", "/proxy/users/v1%2Flist", "/v1%2Flist"},
+		{router, "GET", "/dirs/x%2Fy/z", "x/y|/z"},
+		{normalized, "GET", "//files/a/b", "/a/b"},
+		{normalized, "GET", "/files//a%2Fb/", "/a%2Fb"},
+	}
+
+	for _, tt := range tests {
+		router := tt.router
+		req := httptest.NewRequest(tt.method, tt.url, nil)
+		w := httptest.NewRecorder()
+		c := context.Acquire()
+		c.Reset(w, req)
+		router.ServeHTTP(w, req, c)
+		context.Release(c)
+
+		if w.Body.String() != tt.expected {
+			t.Errorf("Expected catch-all %q for %s, got %q", tt.expected, tt.url, w.Body.String())
+		}
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliwert/go-wolf/pkg/request"
 )
 
 // Constraint represents a parameter constraint
@@ -69,6 +73,28 @@ var (
 		datePattern := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
 		return datePattern.MatchString(value)
 	}
+
+	// IsBase64 validates padded standard base64
+	IsBase64 = request.IsBase64
+
+	// IsBase64URL validates URL-safe base64, with or without padding
+	IsBase64URL = request.IsBase64URL
+
+	// IsHex checks if the value contains only hexadecimal digits
+	IsHex = request.IsHex
+
+	// IsCountryCode validates an ISO 3166-1 alpha-2 country code, ignoring case
+	IsCountryCode = request.IsCountryCode
+
+	// IsLanguageCode validates an ISO 639-1 language code, ignoring case
+	IsLanguageCode = request.IsLanguageCode
+
+	// IsSemVer validates a semantic version, e.g. 1.2.3 or 1.0.0-rc.1+build5
+	IsSemVer = request.IsSemVer
+
+	// IsLuhn validates a Luhn checksum, as used by credit card numbers.
+	// Spaces and dashes are ignored.
+	IsLuhn = request.IsLuhn
 )
 
 // MinLength creates a constraint that checks minimum length
@@ -135,6 +161,40 @@ func OneOf(allowed ...string) Constraint {
 	}
 }
 
+// InSet creates a constraint that checks the value against a set loaded by
+// fn on every validation, for allowed values that change at runtime
+func InSet(fn func() map[string]bool) Constraint {
+	return func(value string) bool {
+		return fn()[value]
+	}
+}
+
+// InSetTTL is InSet with the loaded set memoized for ttl, so fn runs at
+// most once per ttl. A zero ttl reloads on every validation.
+func InSetTTL(fn func() map[string]bool, ttl time.Duration) Constraint {
+	if ttl <= 0 {
+		return InSet(fn)
+	}
+
+	var (
+		mu       sync.Mutex
+		set      map[string]bool
+		loadedAt time.Time
+	)
+
+	return func(value string) bool {
+		mu.Lock()
+		if set == nil || time.Since(loadedAt) >= ttl {
+			set = fn()
+			loadedAt = time.Now()
+		}
+		current := set
+		mu.Unlock()
+
+		return current[value]
+	}
+}
+
 // Regex creates a constraint that validates against a regular expression
 func Regex(pattern string) Constraint {
 	regex := regexp.MustCompile(pattern)
@@ -178,3 +238,20 @@ func Not(constraint Constraint) Constraint {
 		return !constraint(value)
 	}
 }
+
+// Xor combines two constraints with exclusive-or logic
+func Xor(a, b Constraint) Constraint {
+	return func(value string) bool {
+		return a(value) != b(value)
+	}
+}
+
+// If applies then when cond holds and otherwise applies otherwise
+func If(cond, then, otherwise Constraint) Constraint {
+	return func(value string) bool {
+		if cond(value) {
+			return then(value)
+		}
+		return otherwise(value)
+	}
+}
@@ -1,7 +1,9 @@
 package router
 
 import (
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestIsNumeric(t *testing.T) {
@@ -203,6 +205,159 @@ func TestIsDate(t *testing.T) {
 	}
 }
 
+func TestIsBase64(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"aGVsbG8=", true},
+		{"aGVsbG8gd29ybGQ=", true},
+		{"YWJj", true},
+		{"", false},
+		{"aGVsbG8", false},   // Missing padding
+		{"aGVsbG8==", false}, // Bad padding
+		{"aGV$bG8=", false},  // Invalid alphabet
+		{"_-_-", false},      // URL alphabet
+	}
+
+	for _, test := range tests {
+		result := IsBase64(test.input)
+		if result != test.expected {
+			t.Errorf("IsBase64(%s) = %t, expected %t", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestIsBase64URL(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"_-_-", true},
+		{"aGVsbG8", true},
+		{"aGVsbG8=", true},
+		{"", false},
+		{"aGVsbG8==", false}, // Bad padding
+		{"+/+/", false},      // Standard alphabet
+		{"a", false},         // Truncated
+	}
+
+	for _, test := range tests {
+		result := IsBase64URL(test.input)
+		if result != test.expected {
+			t.Errorf("IsBase64URL(%s) = %t, expected %t", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestIsHex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"deadbeef", true},
+		{"DEADBEEF", true},
+		{"0123456789abcdef", true},
+		{"", false},
+		{"0x1f", false},
+		{"ghij", false},
+	}
+
+	for _, test := range tests {
+		result := IsHex(test.input)
+		if result != test.expected {
+			t.Errorf("IsHex(%s) = %t, expected %t", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestIsLuhn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"4111111111111111", true},
+		{"4111 1111 1111 1111", true},
+		{"4111-1111-1111-1111", true},
+		{"4111111111111112", false},
+		{"4111a11111111111", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		result := IsLuhn(test.input)
+		if result != test.expected {
+			t.Errorf("IsLuhn(%s) = %t, expected %t", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestIsCountryCode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"US", true},
+		{"us", true},
+		{"Gb", true},
+		{"XX", false},
+		{"USA", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		result := IsCountryCode(test.input)
+		if result != test.expected {
+			t.Errorf("IsCountryCode(%s) = %t, expected %t", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestIsLanguageCode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"en", true},
+		{"EN", true},
+		{"tr", true},
+		{"zz", false},
+		{"eng", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		result := IsLanguageCode(test.input)
+		if result != test.expected {
+			t.Errorf("IsLanguageCode(%s) = %t, expected %t", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestIsSemVer(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1.2.3", true},
+		{"0.0.0", true},
+		{"1.0.0-rc.1+build5", true},
+		{"2.0.0-alpha.beta", true},
+		{"1.2", false},
+		{"v1.2.3", false},
+		{"01.2.3", false},
+		{"1.2.3-", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		result := IsSemVer(test.input)
+		if result != test.expected {
+			t.Errorf("IsSemVer(%s) = %t, expected %t", test.input, result, test.expected)
+		}
+	}
+}
+
 func TestMinLength(t *testing.T) {
 	constraint := MinLength(5)
 
@@ -365,6 +520,52 @@ func TestOneOf(t *testing.T) {
 	}
 }
 
+func TestInSet(t *testing.T) {
+	tenants := map[string]bool{"acme": true}
+	constraint := InSet(func() map[string]bool { return tenants })
+
+	if !constraint("acme") {
+		t.Error("InSet(acme) = false, expected true")
+	}
+	if constraint("globex") {
+		t.Error("InSet(globex) = true, expected false")
+	}
+
+	tenants = map[string]bool{"acme": true, "globex": true}
+	if !constraint("globex") {
+		t.Error("InSet(globex) = false after update, expected true")
+	}
+}
+
+func TestInSetTTL(t *testing.T) {
+	loads := 0
+	tenants := map[string]bool{"acme": true}
+	constraint := InSetTTL(func() map[string]bool {
+		loads++
+		return tenants
+	}, 20*time.Millisecond)
+
+	if !constraint("acme") || constraint("globex") {
+		t.Error("unexpected result for initial set")
+	}
+
+	tenants = map[string]bool{"globex": true}
+	if constraint("globex") {
+		t.Error("InSetTTL(globex) = true before ttl expired, expected cached set")
+	}
+	if loads != 1 {
+		t.Errorf("expected 1 load within ttl, got %d", loads)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !constraint("globex") {
+		t.Error("InSetTTL(globex) = false after ttl expired, expected reloaded set")
+	}
+	if loads != 2 {
+		t.Errorf("expected 2 loads after ttl, got %d", loads)
+	}
+}
+
 func TestRegex(t *testing.T) {
 	constraint := Regex(`^[a-z]+$`)
 
@@ -479,6 +680,52 @@ func TestNot(t *testing.T) {
 	}
 }
 
+func TestXor(t *testing.T) {
+	isA := Constraint(func(value string) bool { return strings.Contains(value, "a") })
+	isB := Constraint(func(value string) bool { return strings.Contains(value, "b") })
+	constraint := Xor(isA, isB)
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"xyz", false}, // Neither
+		{"a", true},    // Only first
+		{"b", true},    // Only second
+		{"ab", false},  // Both
+	}
+
+	for _, test := range tests {
+		result := constraint(test.input)
+		if result != test.expected {
+			t.Errorf("Xor(isA, isB)(%s) = %t, expected %t", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestIf(t *testing.T) {
+	looksLikeEmail := Constraint(func(value string) bool { return strings.Contains(value, "@") })
+	constraint := If(looksLikeEmail, IsEmail, And(IsAlphaNumeric, MinLength(3)))
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"user@example.com", true}, // Email branch, valid
+		{"user@invalid", false},    // Email branch, invalid
+		{"john123", true},          // Username branch, valid
+		{"jo", false},              // Username branch, too short
+		{"john-doe", false},        // Username branch, not alphanumeric
+	}
+
+	for _, test := range tests {
+		result := constraint(test.input)
+		if result != test.expected {
+			t.Errorf("If(looksLikeEmail, IsEmail, username)(%s) = %t, expected %t", test.input, result, test.expected)
+		}
+	}
+}
+
 func TestComplexConstraintCombinations(t *testing.T) {
 	// Test complex combination: (IsAlpha OR IsNumeric) AND MinLength(2) AND MaxLength(10)
 	constraint := And(
@@ -14,7 +14,7 @@ func (g *Group) Group(prefix string, middleware ...context.HandlerFunc) *Group {
 	return &Group{
 		router:     g.router,
 		prefix:     g.prefix + prefix,
-		middleware: append(g.middleware, middleware...),
+		middleware: combineMiddleware(g.middleware, middleware),
 	}
 }
 
@@ -58,10 +58,28 @@ func (g *Group) OPTIONS(path string, handler context.HandlerFunc, middleware ...
 	g.handle("OPTIONS", path, handler, middleware...)
 }
 
+// anyMethods lists the methods registered by Any
+var anyMethods = []string{"GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS"}
+
+// Any adds a route matching every standard method to the group
+func (g *Group) Any(path string, handler context.HandlerFunc, middleware ...context.HandlerFunc) {
+	for _, method := range anyMethods {
+		g.handle(method, path, handler, middleware...)
+	}
+}
+
 // handle adds a route with the given method to the group
 func (g *Group) handle(method, path string, handler context.HandlerFunc, middleware ...context.HandlerFunc) {
 	// Combine group middleware with route-specific middleware
-	allMiddleware := append(g.middleware, middleware...)
+	allMiddleware := combineMiddleware(g.middleware, middleware)
 	fullPath := g.prefix + path
 	g.router.Handle(method, fullPath, handler, allMiddleware...)
 }
+
+// combineMiddleware returns outer followed by inner in a new slice, so
+// sibling groups and routes never share, and overwrite, a backing array
+func combineMiddleware(outer, inner []context.HandlerFunc) []context.HandlerFunc {
+	combined := make([]context.HandlerFunc, 0, len(outer)+len(inner))
+	combined = append(combined, outer...)
+	return append(combined, inner...)
+}
@@ -331,6 +331,34 @@ func TestGroupWildcardRoutes(t *testing.T) {
 	context.Release(c)
 }
 
+func TestGroupAnyCatchAll(t *testing.T) {
+	router := New()
+	proxy := router.Group("/proxy")
+
+	proxy.Any("/*path", func(c *context.Context) error {
+		return c.String(http.StatusOK, c.Request.Method+" "+c.Param("path"))
+	})
+
+	for _, method := range []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"} {
+		req := httptest.NewRequest(method, "/proxy/foo/bar", nil)
+		w := httptest.NewRecorder()
+		c := context.Acquire()
+		c.Reset(w, req)
+
+		router.ServeHTTP(w, req, c)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", method, w.Code)
+		}
+
+		if expected := method + " /foo/bar"; w.Body.String() != expected {
+			t.Errorf("%s: expected body '%s', got '%s'", method, expected, w.Body.String())
+		}
+
+		context.Release(c)
+	}
+}
+
 func TestGroupConflictingRoutes(t *testing.T) {
 	router := New()
 	users := router.Group("/users")
@@ -417,3 +445,72 @@ func TestGroupStaticVsParam(t *testing.T) {
 
 	context.Release(c)
 }
+
+func TestGroupMiddlewareStopsChain(t *testing.T) {
+	router := New()
+
+	var ran []string
+	deny := func(c *context.Context) error {
+		ran = append(ran, "outer")
+		return c.String(http.StatusForbidden, "forbidden")
+	}
+	inner := func(c *context.Context) error {
+		ran = append(ran, "inner")
+		return c.Next()
+	}
+
+	api := router.Group("/api", deny)
+	admin := api.Group("/admin", inner)
+	admin.GET("/stats", func(c *context.Context) error {
+		ran = append(ran, "handler")
+		return c.String(http.StatusOK, "stats")
+	}, inner)
+
+	req := httptest.NewRequest("GET", "/api/admin/stats", nil)
+	w := httptest.NewRecorder()
+	c := context.Acquire()
+	c.Reset(w, req)
+	router.ServeHTTP(w, req, c)
+	context.Release(c)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+	if len(ran) != 1 || ran[0] != "outer" {
+		t.Errorf("Expected only the outer middleware to run, got %v", ran)
+	}
+}
+
+func TestGroupSiblingMiddlewareIsolated(t *testing.T) {
+	router := New()
+
+	tag := func(value string) context.HandlerFunc {
+		return func(c *context.Context) error {
+			c.SetHeader("X-Tag", c.Header("X-Tag")+value)
+			return c.Next()
+		}
+	}
+
+	// Spare capacity in the parent slice used to let siblings overwrite
+	// each other's middleware
+	api := router.Group("/api", make([]context.HandlerFunc, 0, 4)...)
+	api.Use(tag("a"))
+	users := api.Group("/users", tag("u"))
+	orders := api.Group("/orders", tag("o"))
+
+	users.GET("", func(c *context.Context) error { return c.String(http.StatusOK, "users") })
+	orders.GET("", func(c *context.Context) error { return c.String(http.StatusOK, "orders") })
+
+	for path, expected := range map[string]string{"/api/users": "au", "/api/orders": "ao"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		c := context.Acquire()
+		c.Reset(w, req)
+		router.ServeHTTP(w, req, c)
+		context.Release(c)
+
+		if got := w.Header().Get("X-Tag"); got != expected {
+			t.Errorf("Expected X-Tag '%s' for %s, got '%s'", expected, path, got)
+		}
+	}
+}
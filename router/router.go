@@ -1,19 +1,40 @@
 package router
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aliwert/go-wolf/pkg/context"
+	"github.com/aliwert/go-wolf/pkg/response"
 )
 
-// Router represents the HTTP router
+// Router represents the HTTP router. Routes may be registered and settings
+// changed from several goroutines; registration, settings and lookups are
+// all guarded by mu.
 type Router struct {
+	preRouting              atomic.Pointer[[]context.HandlerFunc] // copy-on-write, read without mu
+	mu                      sync.RWMutex                          // guards every field below
 	trees                   map[string]*node
 	routes                  []*RouteInfo
 	namedRoutes             map[string]*RouteInfo
 	notFoundHandler         context.HandlerFunc
+	prefixNotFound          map[string]context.HandlerFunc // path prefix -> 404 handler
 	methodNotAllowedHandler context.HandlerFunc
-	constraints             map[string]map[string]Constraint // path -> param -> constraint
+	alternates              map[string][]*alternateRoute          // "METHOD route" -> routes sharing its tree node
+	constraints             map[string]map[string]RouteConstraint // "METHOD path" -> param -> constraint
+	constraintFailStatus    int
+	contentTypeCheck        ContentTypeCheck
+	normalizePaths          bool
+	describeOptions         bool
+	rawCatchAll             map[string]bool                     // "METHOD path" -> keep catch-all percent-encoded
+	preflights              map[string]*response.CORSMiddleware // path -> policy of its registered preflight route
 }
 
 // RouteInfo represents information about a registered route
@@ -25,6 +46,22 @@ type RouteInfo struct {
 	Middleware  []context.HandlerFunc
 	Constraints map[string]Constraint
 	Subdomain   string
+	Timeout     time.Duration
+	Produces    string
+	Accepts     reflect.Type
+
+	// handle is Handler with its route-level wrappers but without
+	// Middleware, kept so the chain can be rebuilt by SetMiddleware
+	handle context.HandlerFunc
+}
+
+// alternateRoute is a route that differs from one in the tree only in its
+// param names, e.g. "/users/:name" next to "/users/:id". It shares that
+// route's tree node and is tried when the route's constraints fail.
+type alternateRoute struct {
+	path   string
+	params []string // param names in path order
+	handle context.HandlerFunc
 }
 
 // Route represents a route with additional metadata
@@ -35,6 +72,9 @@ type Route struct {
 
 // Name sets the name for this route
 func (r *Route) Name(name string) *Route {
+	r.router.mu.Lock()
+	defer r.router.mu.Unlock()
+
 	r.info.Name = name
 	if r.router.namedRoutes == nil {
 		r.router.namedRoutes = make(map[string]*RouteInfo)
@@ -43,6 +83,48 @@ func (r *Route) Name(name string) *Route {
 	return r
 }
 
+// Where constrains a path parameter of this route
+func (r *Route) Where(param string, constraint Constraint) *Route {
+	r.router.setConstraint(r.info.Method, r.info.Path, RouteConstraint{Name: param, Rule: "custom", Checker: constraint})
+
+	r.router.mu.Lock()
+	defer r.router.mu.Unlock()
+	if r.info.Constraints == nil {
+		r.info.Constraints = make(map[string]Constraint)
+	}
+	r.info.Constraints[param] = constraint
+	return r
+}
+
+// GetMiddleware returns a copy of the route's middleware
+func (r *Route) GetMiddleware() []context.HandlerFunc {
+	r.router.mu.RLock()
+	defer r.router.mu.RUnlock()
+	return append([]context.HandlerFunc(nil), r.info.Middleware...)
+}
+
+// SetMiddleware replaces the route's middleware and rebuilds its handler
+// chain in the tree. Requests already dispatched finish with the old chain.
+func (r *Route) SetMiddleware(middleware ...context.HandlerFunc) *Route {
+	r.router.mu.Lock()
+	defer r.router.mu.Unlock()
+
+	handle := r.info.handle
+	if handle == nil {
+		handle = r.info.Handler
+	}
+
+	r.info.Middleware = append([]context.HandlerFunc(nil), middleware...)
+	chain := chainMiddleware(r.info.Middleware, handle)
+	if alt := r.router.alternate(r.info.Method, r.info.Path); alt != nil {
+		alt.handle = chain
+	} else if root := r.router.trees[r.info.Method]; root != nil {
+		// Re-adding an existing path replaces its handle
+		root.addRoute(r.info.Path, chain)
+	}
+	return r
+}
+
 // New creates a new router
 func New() *Router {
 	return &Router{
@@ -50,6 +132,17 @@ func New() *Router {
 	}
 }
 
+// NewWithOptions creates a new router configured by opts
+func NewWithOptions(opts RouterOptions) *Router {
+	r := New()
+	r.notFoundHandler = opts.NotFoundHandler
+	r.methodNotAllowedHandler = opts.MethodNotAllowedHandler
+	r.constraintFailStatus = opts.ConstraintFailStatus
+	r.normalizePaths = opts.NormalizePaths
+	r.describeOptions = opts.DescribeOptions
+	return r
+}
+
 // Handle registers a new request handle with the given path and method
 func (r *Router) Handle(method, path string, handler context.HandlerFunc, middleware ...context.HandlerFunc) {
 	if method == "" {
@@ -62,6 +155,12 @@ func (r *Router) Handle(method, path string, handler context.HandlerFunc, middle
 		panic("handler must not be nil")
 	}
 
+	original := path
+	path = r.cleanPath(path)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	// Get or create tree for method
 	root := r.trees[method]
 	if root == nil {
@@ -69,20 +168,177 @@ func (r *Router) Handle(method, path string, handler context.HandlerFunc, middle
 		r.trees[method] = root
 	}
 
-	// Build middleware chain
+	// The tree silently overwrites duplicates; with normalization on,
+	// "/users" and "/users/" would collide unnoticed
+	if r.normalizePaths {
+		if handle, _, route, _ := root.lookup(path); handle != nil && route == path {
+			panic("a handle is already registered for path '" + path +
+				"' (normalized from '" + original + "')")
+		}
+	}
+
+	handle := chainMiddleware(middleware, handler)
+
+	// A re-registered route starts without the constraints or catch-all
+	// mode of the route it replaces; callers set its own after registering
+	// it
+	delete(r.constraints, constraintKey(method, path))
+	delete(r.rawCatchAll, constraintKey(method, path))
+	if method == http.MethodOptions {
+		delete(r.preflights, path)
+	}
+
+	// A path that only renames the params of a registered route would
+	// conflict in the tree, so it is kept alongside that route instead.
+	// Alternates are only tried when the route's constraints fail, so
+	// behind an unconstrained route the tree's conflict panic stands.
+	if route := sameShapeRoute(root, path); route != "" {
+		key := constraintKey(method, route)
+		if len(r.constraints[key]) > 0 {
+			r.addAlternate(key, path, handle)
+			return
+		}
+	}
+
+	root.addRoute(path, handle)
+}
+
+// addAlternate records path as an alternate of the tree route at key,
+// replacing an earlier registration of the same path. The caller must
+// hold r.mu.
+func (r *Router) addAlternate(key, path string, handle context.HandlerFunc) {
+	for _, alt := range r.alternates[key] {
+		if alt.path == path {
+			alt.handle = handle
+			return
+		}
+	}
+
+	if r.alternates == nil {
+		r.alternates = make(map[string][]*alternateRoute)
+	}
+	r.alternates[key] = append(r.alternates[key], &alternateRoute{
+		path:   path,
+		params: routeParams(path),
+		handle: handle,
+	})
+}
+
+// alternate returns the alternate route registered for method and path,
+// or nil. The caller must hold r.mu.
+func (r *Router) alternate(method, path string) *alternateRoute {
+	prefix := method + " "
+	for key, alts := range r.alternates {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		for _, alt := range alts {
+			if alt.path == path {
+				return alt
+			}
+		}
+	}
+	return nil
+}
+
+// TryHandle registers a route like Handle, but returns an error instead of
+// panicking when the route is invalid or conflicts with an existing one
+func (r *Router) TryHandle(method, path string, handler context.HandlerFunc, middleware ...context.HandlerFunc) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("%v", rec)
+		}
+	}()
+
+	r.Handle(method, path, handler, middleware...)
+	return nil
+}
+
+// HandleRoute registers a route like Handle and returns it, so it can be
+// named or constrained inline:
+//
+//	r.HandleRoute("GET", "/users/:id", show).Name("users.show")
+func (r *Router) HandleRoute(method, path string, handler context.HandlerFunc, middleware ...context.HandlerFunc) *Route {
+	r.Handle(method, path, handler, middleware...)
+	path = r.cleanPath(path)
+
+	info := &RouteInfo{
+		Method:     method,
+		Path:       path,
+		Handler:    handler,
+		Middleware: middleware,
+		handle:     handler,
+	}
+	r.storeRouteInfo(info)
+
+	return &Route{
+		info:   info,
+		router: r,
+	}
+}
+
+// setConstraint records a param constraint for the method's route at path
+func (r *Router) setConstraint(method, path string, rc RouteConstraint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := constraintKey(method, path)
+	if r.constraints == nil {
+		r.constraints = make(map[string]map[string]RouteConstraint)
+	}
+	if r.constraints[key] == nil {
+		r.constraints[key] = make(map[string]RouteConstraint)
+	}
+	r.constraints[key][rc.Name] = rc
+}
+
+// constraintKey keys per-route state by method and path, so the same path
+// registered for several methods keeps separate constraints
+func constraintKey(method, path string) string {
+	return method + " " + path
+}
+
+// cleanPath normalizes a registration or request path when path
+// normalization is enabled, and returns it unchanged otherwise
+func (r *Router) cleanPath(path string) string {
+	r.mu.RLock()
+	normalize := r.normalizePaths
+	r.mu.RUnlock()
+
+	if !normalize {
+		return path
+	}
+	return NewRouteUtils().NormalizePath(path)
+}
+
+// chainMiddleware wraps handler with middleware, outermost first.
+//
+// Each middleware decides what its caller sees: returning nil swallows an
+// error from further down the chain, e.g. after recovering from it. When a
+// middleware does fail after an inner handler or middleware already has,
+// the inner error wins, so ServeHTTP invokes the error handler exactly once
+// with the original error.
+func chainMiddleware(middleware []context.HandlerFunc, handler context.HandlerFunc) context.HandlerFunc {
 	finalHandler := handler
 	for i := len(middleware) - 1; i >= 0; i-- {
 		mw := middleware[i]
 		next := finalHandler
 		finalHandler = func(mw context.HandlerFunc, next context.HandlerFunc) context.HandlerFunc {
 			return func(c *context.Context) error {
-				c.SetNext(next)
-				return mw(c)
+				var innerErr error
+				c.SetNext(func(c *context.Context) error {
+					innerErr = next(c)
+					return innerErr
+				})
+				err := mw(c)
+				if err != nil && innerErr != nil {
+					return innerErr
+				}
+				return err
 			}
 		}(mw, next)
 	}
-
-	root.addRoute(path, finalHandler)
+	return finalHandler
 }
 
 // Group creates a new route group with the given prefix
@@ -94,41 +350,478 @@ func (r *Router) Group(prefix string, middleware ...context.HandlerFunc) *Group
 	}
 }
 
-// ServeHTTP implements the http.Handler interface
+// UsePreRouting adds middleware that runs before routing, so it also sees
+// requests answered with 404, 405 or an automatic OPTIONS reply, and
+// headers it sets before calling c.Next, such as CORS or security headers,
+// reach those responses too. Route and group middleware only run for
+// matched routes.
+func (r *Router) UsePreRouting(middleware ...context.HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var chain []context.HandlerFunc
+	if current := r.preRouting.Load(); current != nil {
+		chain = append(chain, *current...)
+	}
+	chain = append(chain, middleware...)
+	r.preRouting.Store(&chain)
+}
+
+// ServeHTTP implements the http.Handler interface.
+//
+// Methods are resolved in order: exact match, then HEAD served by the GET
+// handler, then an automatic OPTIONS reply, then 405 and finally 404.
+// Pre-routing middleware wraps all of these.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request, c *context.Context) {
-	method := req.Method
+	preRouting := r.preRouting.Load()
+	if preRouting == nil {
+		r.dispatch(req, c)
+		return
+	}
+
+	r.handleError(c, chainMiddleware(*preRouting, func(c *context.Context) error {
+		r.dispatch(req, c)
+		return nil
+	})(c))
+}
+
+// resolution is the outcome of routing a request: a handler to run, a
+// constraint failure to report, the methods allowed for an automatic
+// OPTIONS reply or 405, or a 404
+type resolution struct {
+	handle    context.HandlerFunc
+	params    map[string]string
+	route     string
+	failed    *RouteConstraint // reported as 400
+	raw       bool             // keep the catch-all percent-encoded
+	normalize bool
+
+	allowed          []string
+	description      *OptionsDescription
+	methodNotAllowed context.HandlerFunc
+	notFound         context.HandlerFunc
+}
+
+// resolve routes req under a single read lock, so serving a request never
+// waits on the lock more than once
+func (r *Router) resolve(req *http.Request) resolution {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := resolution{normalize: r.normalizePaths}
 	path := req.URL.Path
+	if res.normalize {
+		path = NewRouteUtils().NormalizePath(path)
+	}
 
-	if root := r.trees[method]; root != nil {
-		if handle, params, _ := root.getValue(path); handle != nil {
-			if params != nil {
-				c.SetParams(params)
-			}
-			if err := handle(c); err != nil {
-				if errorHandler := c.GetErrorHandler(); errorHandler != nil {
-					errorHandler(c, err)
+	// Exact method first, then HEAD served by the GET handler. A failed
+	// param constraint counts as no match unless reported as 400.
+	methods := []string{req.Method}
+	if req.Method == http.MethodHead {
+		methods = append(methods, http.MethodGet)
+	}
+	for _, method := range methods {
+		handle, params, route, failed := r.match(method, path)
+		if handle == nil || (failed != nil && r.constraintFailStatus != http.StatusBadRequest) {
+			continue
+		}
+		res.handle, res.params, res.route, res.failed = handle, params, route, failed
+		if len(r.rawCatchAll) > 0 {
+			res.raw = r.rawCatchAll[constraintKey(method, route)]
+		}
+		return res
+	}
+
+	if res.allowed = r.allowedMethods(path); len(res.allowed) > 0 {
+		if req.Method == http.MethodOptions && r.describeOptions {
+			description := r.describe(path, res.allowed)
+			res.description = &description
+		}
+		res.methodNotAllowed = r.methodNotAllowedHandler
+		return res
+	}
+
+	res.notFound = r.notFoundHandlerFor(path)
+	return res
+}
+
+// dispatch routes req to its handler or answers it with a constraint
+// failure, an automatic OPTIONS reply, 405 or 404
+func (r *Router) dispatch(req *http.Request, c *context.Context) {
+	res := r.resolve(req)
+
+	switch {
+	case res.failed != nil:
+		c.Writer.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(c.Writer, "parameter '%s' failed constraint '%s'", res.failed.Name, res.failed.Rule)
+
+	case res.handle != nil:
+		if res.params != nil {
+			// Only build the escaped path for routes that have a raw catch-all
+			if res.raw && strings.Contains(res.route, "/*") {
+				escaped := req.URL.EscapedPath()
+				if res.normalize {
+					escaped = NewRouteUtils().NormalizePath(escaped)
 				}
+				rawCatchAllParams(res.route, escaped, res.params)
+			}
+			c.SetParams(res.params)
+		}
+		r.handleError(c, res.handle(c))
+
+	case len(res.allowed) > 0:
+		c.Writer.Header().Set("Allow", allowHeader(res.allowed))
+
+		// Auto-OPTIONS: answer with the allowed methods
+		if req.Method == http.MethodOptions {
+			if res.description != nil {
+				r.handleError(c, c.JSON(http.StatusOK, res.description))
+				return
 			}
+			c.Writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Handle 405 Method Not Allowed
+		if res.methodNotAllowed != nil {
+			r.handleError(c, res.methodNotAllowed(c))
+			return
+		}
+		c.Writer.WriteHeader(http.StatusMethodNotAllowed)
+		c.Writer.Write([]byte("Method Not Allowed"))
+
+	case res.notFound != nil:
+		r.handleError(c, res.notFound(c))
+
+	default:
+		c.Writer.WriteHeader(http.StatusNotFound)
+		c.Writer.Write([]byte("Not Found"))
+	}
+}
+
+// match looks up the handler for method and path and checks its param
+// constraints. When they fail, the route's alternates are tried in
+// registration order; if none passes, the route's first failing
+// constraint is returned. The caller must hold r.mu.
+func (r *Router) match(method, path string) (context.HandlerFunc, map[string]string, string, *RouteConstraint) {
+	root := r.trees[method]
+	if root == nil {
+		return nil, nil, "", nil
+	}
+
+	handle, params, route, _ := root.lookup(path)
+	if handle == nil {
+		return nil, nil, "", nil
+	}
+
+	failed := r.failedConstraint(method, route, params)
+	if failed == nil {
+		return handle, params, route, nil
+	}
+
+	alts := r.alternates[constraintKey(method, route)]
+	if len(alts) == 0 {
+		return handle, params, route, failed
+	}
+
+	names := routeParams(route)
+	for _, alt := range alts {
+		altParams := make(map[string]string, len(params))
+		for i, name := range names {
+			altParams[alt.params[i]] = params[name]
+		}
+		if r.failedConstraint(method, alt.path, altParams) == nil {
+			return alt.handle, altParams, alt.path, nil
+		}
+	}
+	return handle, params, route, failed
+}
+
+// failedConstraint returns the first constraint of the method's route,
+// in param order, that params fail, or nil. The caller must hold r.mu.
+func (r *Router) failedConstraint(method, route string, params map[string]string) *RouteConstraint {
+	// Skip building the key on the hot path when nothing can fail
+	if len(params) == 0 || len(r.constraints) == 0 {
+		return nil
+	}
+
+	constraints := r.constraints[constraintKey(method, route)]
+	if len(constraints) == 0 {
+		return nil
+	}
+
+	for _, param := range routeParams(route) {
+		rc, ok := constraints[param]
+		if ok && rc.Checker != nil && !rc.Checker(params[param]) {
+			return &rc
+		}
+	}
+	return nil
+}
+
+// sameShapeRoute returns the route in root that path only renames the
+// params of, e.g. "/users/:id" for "/users/:name", or "" if there is none
+func sameShapeRoute(root *node, path string) string {
+	shape := routeShape(path)
+	if shape == path {
+		return ""
+	}
+
+	// Look up a concrete path that only the wildcard segments can match
+	probe := strings.NewReplacer("/:", "/_", "/*", "/_").Replace(shape)
+	handle, _, route, _ := root.lookup(probe)
+	if handle == nil || route == path || routeShape(route) != shape {
+		return ""
+	}
+	return route
+}
+
+// routeShape drops the param names from path, so "/users/:id" and
+// "/users/:name" both become "/users/:"
+func routeShape(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment != "" && (segment[0] == ':' || segment[0] == '*') {
+			segments[i] = segment[:1]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// routeParams returns the param names of path in order, without any
+// catch-all options
+func routeParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		names = append(names, paramName(segment[1:]))
+	}
+	return names
+}
+
+// paramName strips any options from a param segment without its leading
+// ':' or '*'
+func paramName(segment string) string {
+	if end := strings.IndexByte(segment, '{'); end >= 0 {
+		return segment[:end]
+	}
+	return segment
+}
+
+// rawCatchAllParams re-reads the params of a catch-all route from escaped, the
+// request's escaped path cleaned the same way as the lookup path, so
+// percent-encodings such as %2F survive in the catch-all value. An encoded
+// slash shifts the decoded match, so the params before the catch-all are
+// re-read too, each taking the decoded escaped segment at its position.
+func rawCatchAllParams(route, escaped string, params map[string]string) {
+	idx := strings.Index(route, "/*")
+	if idx < 0 {
+		return
+	}
+
+	name := paramName(route[idx+2:])
+	if _, ok := params[name]; !ok {
+		return
+	}
+
+	prefix := strings.Split(route[:idx], "/")
+	segments := strings.Split(escaped, "/")
+	if len(segments) <= len(prefix) {
+		return
+	}
+
+	for i, segment := range prefix {
+		if segment == "" || segment[0] != ':' {
+			continue
+		}
+		value, err := url.PathUnescape(segments[i])
+		if err != nil {
 			return
 		}
+		params[paramName(segment[1:])] = value
 	}
+	params[name] = "/" + strings.Join(segments[len(prefix):], "/")
+}
 
-	// Handle 405 Method Not Allowed
-	for m := range r.trees {
-		if m != method {
-			if root := r.trees[m]; root != nil {
-				if handle, _, _ := root.getValue(path); handle != nil {
-					c.Writer.WriteHeader(http.StatusMethodNotAllowed)
-					c.Writer.Write([]byte("Method Not Allowed"))
-					return
+// handleError passes a non-nil handler error to the context's error handler
+func (r *Router) handleError(c *context.Context, err error) {
+	if err == nil {
+		return
+	}
+	if errorHandler := c.GetErrorHandler(); errorHandler != nil {
+		errorHandler(c, err)
+	}
+}
+
+// allowHeader builds the Allow header value for the given registered
+// methods, adding the implicit HEAD and OPTIONS
+func allowHeader(methods []string) string {
+	allowed := append([]string{}, methods...)
+	hasHead, hasOptions, hasGet := false, false, false
+	for _, m := range methods {
+		switch m {
+		case http.MethodGet:
+			hasGet = true
+		case http.MethodHead:
+			hasHead = true
+		case http.MethodOptions:
+			hasOptions = true
+		}
+	}
+	if hasGet && !hasHead {
+		allowed = append(allowed, http.MethodHead)
+	}
+	if !hasOptions {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	sort.Strings(allowed)
+	return strings.Join(allowed, ", ")
+}
+
+// AllowedMethods returns the sorted methods that have a route matching path
+func (r *Router) AllowedMethods(path string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.allowedMethods(path)
+}
+
+// allowedMethods implements AllowedMethods. The caller must hold r.mu.
+func (r *Router) allowedMethods(path string) []string {
+	var methods []string
+	for method := range r.trees {
+		if handle, _, _, failed := r.match(method, path); handle != nil && failed == nil {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// Validate checks the router for registration mistakes: inconsistent
+// method trees and named routes that do not resolve to a registered
+// route. It returns every problem found, or nil.
+func (r *Router) Validate() []error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	methods := make([]string, 0, len(r.trees))
+	for method := range r.trees {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	var errs []error
+	for _, method := range methods {
+		errs = append(errs, r.trees[method].check(method, "")...)
+	}
+
+	names := make([]string, 0, len(r.namedRoutes))
+	for name := range r.namedRoutes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		info := r.namedRoutes[name]
+		root := r.trees[info.Method]
+		if root == nil {
+			errs = append(errs, fmt.Errorf("named route '%s': no %s routes registered", name, info.Method))
+			continue
+		}
+		if handle, _, route, _ := root.lookup(info.Path); handle == nil ||
+			(route != info.Path && r.alternate(info.Method, info.Path) == nil) {
+			errs = append(errs, fmt.Errorf("named route '%s': %s %s is not registered", name, info.Method, info.Path))
+		}
+	}
+
+	return errs
+}
+
+// OptionsDescription is the body of a described automatic OPTIONS response
+type OptionsDescription struct {
+	Allow  []string           `json:"allow"`
+	Routes []RouteDescription `json:"routes"`
+}
+
+// RouteDescription describes one route matching an OPTIONS request
+type RouteDescription struct {
+	Method string             `json:"method"`
+	Path   string             `json:"path"`
+	Params []ParamDescription `json:"params,omitempty"`
+}
+
+// ParamDescription names a route param and the rule constraining it, if any
+type ParamDescription struct {
+	Name       string `json:"name"`
+	Constraint string `json:"constraint,omitempty"`
+}
+
+// describe builds the OPTIONS description of the routes matching path
+// for the given allowed methods. The caller must hold r.mu.
+func (r *Router) describe(path string, allowed []string) OptionsDescription {
+	desc := OptionsDescription{
+		Allow:  strings.Split(allowHeader(allowed), ", "),
+		Routes: make([]RouteDescription, 0, len(allowed)),
+	}
+
+	for _, method := range allowed {
+		_, _, route, _ := r.trees[method].lookup(path)
+
+		desc.Routes = append(desc.Routes, r.describeRoute(method, route))
+		for _, alt := range r.alternates[constraintKey(method, route)] {
+			desc.Routes = append(desc.Routes, r.describeRoute(method, alt.path))
+		}
+	}
+	return desc
+}
+
+// describeRoute describes the method's route and its param constraints.
+// The caller must hold r.mu.
+func (r *Router) describeRoute(method, route string) RouteDescription {
+	rd := RouteDescription{Method: method, Path: route}
+	pattern := NewRouteUtils().ParseRoutePattern(route)
+	for _, name := range append(pattern.Params, pattern.Wildcards...) {
+		rd.Params = append(rd.Params, ParamDescription{
+			Name:       name,
+			Constraint: r.constraints[constraintKey(method, route)][name].Rule,
+		})
+	}
+	return rd
+}
+
+// Walk calls fn for every route in the radix trees, in method order,
+// each followed by its alternates. Walking stops at the first error, which
+// is returned. fn must not register routes.
+func (r *Router) Walk(fn func(method, path string, handler context.HandlerFunc) error) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	methods := make([]string, 0, len(r.trees))
+	for method := range r.trees {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	for _, method := range methods {
+		err := r.trees[method].walk("", func(path string, handle context.HandlerFunc) error {
+			if err := fn(method, path, handle); err != nil {
+				return err
+			}
+			for _, alt := range r.alternates[constraintKey(method, path)] {
+				if err := fn(method, alt.path, alt.handle); err != nil {
+					return err
 				}
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 	}
 
-	// Handle 404 Not Found
-	c.Writer.WriteHeader(http.StatusNotFound)
-	c.Writer.Write([]byte("Not Found"))
+	return nil
 }
 
 // RouterOptions holds router configuration
@@ -137,6 +830,16 @@ type RouterOptions struct {
 	MethodNotAllowedHandler context.HandlerFunc
 	EnableCaching           bool
 	CacheSize               int
+	// ConstraintFailStatus is the status sent when a route matches but a
+	// param constraint fails: http.StatusNotFound (default) keeps the
+	// response opaque, http.StatusBadRequest names the failing param
+	ConstraintFailStatus int
+	// NormalizePaths cleans registered and requested paths with
+	// RouteUtils.NormalizePath, so "/users/" and "/users" are one route
+	NormalizePaths bool
+	// DescribeOptions makes automatic OPTIONS responses carry a JSON
+	// description of the matching routes and their param constraints
+	DescribeOptions bool
 }
 
 // Utility functions for the radix tree
@@ -153,6 +856,17 @@ func countParams(path string) uint8 {
 	return n
 }
 
+// segmentCount counts the non-empty segments in a slash-separated path
+func segmentCount(path string) int {
+	n := 0
+	for i := 0; i < len(path); i++ {
+		if path[i] != '/' && (i == 0 || path[i-1] == '/') {
+			n++
+		}
+	}
+	return n
+}
+
 // longestCommonPrefix finds the longest common prefix between two strings
 func longestCommonPrefix(a, b string) int {
 	i := 0
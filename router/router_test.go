@@ -1,11 +1,15 @@
 package router
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 
 	"github.com/aliwert/go-wolf/pkg/context"
+	"github.com/aliwert/go-wolf/pkg/response"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -123,6 +127,567 @@ func TestRouter_ErrorHandling(t *testing.T) {
 	})
 }
 
+func TestRouter_Walk(t *testing.T) {
+	router := New()
+	router.Handle("GET", "/", simpleHandler("root"))
+	router.Handle("GET", "/users", simpleHandler("users"))
+	router.Handle("GET", "/users/:id", paramHandler)
+	router.Handle("GET", "/users/:id/posts", simpleHandler("posts"))
+	router.Handle("GET", "/static/*filepath", simpleHandler("static"))
+	router.Handle("POST", "/users", simpleHandler("create"))
+
+	var routes []string
+	err := router.Walk(func(method, path string, handler context.HandlerFunc) error {
+		assert.NotNil(t, handler)
+		routes = append(routes, method+" "+path)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"GET /",
+		"GET /users",
+		"GET /users/:id",
+		"GET /users/:id/posts",
+		"GET /static/*filepath",
+		"POST /users",
+	}, routes)
+
+	t.Run("StopsOnError", func(t *testing.T) {
+		stop := errors.New("stop")
+		visited := 0
+		err := router.Walk(func(method, path string, handler context.HandlerFunc) error {
+			visited++
+			return stop
+		})
+
+		assert.Equal(t, stop, err)
+		assert.Equal(t, 1, visited)
+	})
+}
+
+func TestRouter_ErrorPropagation(t *testing.T) {
+	errInner := errors.New("inner")
+	errOuter := errors.New("outer")
+	errHandler := errors.New("handler")
+
+	failingMiddleware := func(err error) context.HandlerFunc {
+		return func(c *context.Context) error {
+			c.Next()
+			return err
+		}
+	}
+
+	serve := func(router *Router, path string) (errs []error) {
+		req := httptest.NewRequest("GET", path, nil)
+		resp := httptest.NewRecorder()
+		c := context.Acquire()
+		defer context.Release(c)
+		c.Reset(resp, req)
+
+		// Resolve the route directly so every error reaching the
+		// error handler can be observed
+		handle, _, _ := router.trees["GET"].getValue(path)
+		if err := handle(c); err != nil {
+			errs = append(errs, err)
+		}
+		return errs
+	}
+
+	t.Run("FirstMiddlewareErrorWins", func(t *testing.T) {
+		router := New()
+		router.Handle("GET", "/", simpleHandler("ok"), failingMiddleware(errOuter), failingMiddleware(errInner))
+
+		errs := serve(router, "/")
+		assert.Equal(t, []error{errInner}, errs)
+	})
+
+	t.Run("HandlerErrorWins", func(t *testing.T) {
+		router := New()
+		router.Handle("GET", "/", func(c *context.Context) error {
+			return errHandler
+		}, failingMiddleware(errOuter), failingMiddleware(errInner))
+
+		errs := serve(router, "/")
+		assert.Equal(t, []error{errHandler}, errs)
+	})
+
+	t.Run("MiddlewareSwallowsError", func(t *testing.T) {
+		router := New()
+		recovering := func(c *context.Context) error {
+			c.Next()
+			return nil
+		}
+		router.Handle("GET", "/", func(c *context.Context) error {
+			return errHandler
+		}, failingMiddleware(errOuter), recovering)
+
+		errs := serve(router, "/")
+		assert.Equal(t, []error{errOuter}, errs)
+
+		router = New()
+		router.Handle("GET", "/", func(c *context.Context) error {
+			return errHandler
+		}, recovering, failingMiddleware(errInner))
+
+		errs = serve(router, "/")
+		assert.Empty(t, errs)
+	})
+
+	t.Run("NoErrors", func(t *testing.T) {
+		router := New()
+		router.Handle("GET", "/", simpleHandler("ok"), testMiddleware("a"), testMiddleware("b"))
+
+		errs := serve(router, "/")
+		assert.Empty(t, errs)
+	})
+}
+
+func TestRouter_AllowedMethods(t *testing.T) {
+	router := New()
+	router.Handle("GET", "/items/:id", paramHandler)
+	router.Handle("POST", "/items/:id", paramHandler)
+	router.Handle("DELETE", "/other", simpleHandler("other"))
+
+	assert.Equal(t, []string{"GET", "POST"}, router.AllowedMethods("/items/1"))
+	assert.Empty(t, router.AllowedMethods("/missing"))
+
+	t.Run("CORSPreflight", func(t *testing.T) {
+		cors := response.NewCORSMiddleware()
+		cors.SetAllowedMethodsFunc(func(r *http.Request) []string {
+			return router.AllowedMethods(r.URL.Path)
+		})
+
+		req := httptest.NewRequest("OPTIONS", "/items/1", nil)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		resp := httptest.NewRecorder()
+
+		cors.Wrap(resp, req)
+
+		assert.Equal(t, "GET, POST", resp.Header().Get("Access-Control-Allow-Methods"))
+	})
+}
+
+func TestRouter_MethodPrecedence(t *testing.T) {
+	router := New()
+	router.Handle("GET", "/items", simpleHandler("list"))
+	router.Handle("HEAD", "/explicit", simpleHandler("head"))
+	router.Handle("GET", "/explicit", simpleHandler("get"))
+	router.Handle("OPTIONS", "/custom", simpleHandler("options"))
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		resp := httptest.NewRecorder()
+		c := context.Acquire()
+		defer context.Release(c)
+		c.Reset(resp, req)
+		router.ServeHTTP(resp, req, c)
+		return resp
+	}
+
+	t.Run("ExactMatch", func(t *testing.T) {
+		resp := serve("HEAD", "/explicit")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "head", resp.Body.String())
+	})
+
+	t.Run("AutoHEAD", func(t *testing.T) {
+		resp := serve("HEAD", "/items")
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("AutoOPTIONS", func(t *testing.T) {
+		resp := serve("OPTIONS", "/items")
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		assert.Equal(t, "GET, HEAD, OPTIONS", resp.Header().Get("Allow"))
+	})
+
+	t.Run("ExplicitOPTIONS", func(t *testing.T) {
+		resp := serve("OPTIONS", "/custom")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "options", resp.Body.String())
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		resp := serve("POST", "/items")
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+		assert.Equal(t, "GET, HEAD, OPTIONS", resp.Header().Get("Allow"))
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		for _, method := range []string{"GET", "HEAD", "OPTIONS", "POST"} {
+			resp := serve(method, "/missing")
+			assert.Equal(t, http.StatusNotFound, resp.Code, method)
+		}
+	})
+
+	t.Run("CustomHandlers", func(t *testing.T) {
+		router.SetMethodNotAllowedHandler(func(c *context.Context) error {
+			return c.String(http.StatusMethodNotAllowed, "custom 405")
+		})
+		router.SetNotFoundHandler(func(c *context.Context) error {
+			return c.String(http.StatusNotFound, "custom 404")
+		})
+		defer router.SetMethodNotAllowedHandler(nil)
+		defer router.SetNotFoundHandler(nil)
+
+		resp := serve("DELETE", "/items")
+		assert.Equal(t, "custom 405", resp.Body.String())
+
+		resp = serve("GET", "/missing")
+		assert.Equal(t, "custom 404", resp.Body.String())
+	})
+}
+
+func TestRouter_HandleRoute(t *testing.T) {
+	router := New()
+	router.HandleRoute("GET", "/users/:id", paramHandler).Name("users.show").Where("id", IsNumeric)
+
+	url, err := router.URL("users.show", map[string]string{"id": "42"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/users/42", url)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		resp := httptest.NewRecorder()
+		c := context.Acquire()
+		defer context.Release(c)
+		c.Reset(resp, req)
+		router.ServeHTTP(resp, req, c)
+		return resp
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/users/42").Code)
+	assert.Equal(t, http.StatusNotFound, serve("/users/abc").Code)
+	assert.Len(t, router.GetRoutes(), 1)
+}
+
+func TestRouter_NormalizePaths(t *testing.T) {
+	router := NewWithOptions(RouterOptions{NormalizePaths: true})
+	router.Handle("GET", "/users/", simpleHandler("users"))
+
+	for _, path := range []string{"/users", "/users/", "//users"} {
+		req := httptest.NewRequest("GET", path, nil)
+		resp := httptest.NewRecorder()
+		c := context.Acquire()
+		c.Reset(resp, req)
+		router.ServeHTTP(resp, req, c)
+		context.Release(c)
+
+		assert.Equal(t, http.StatusOK, resp.Code, path)
+		assert.Equal(t, "users", resp.Body.String(), path)
+	}
+
+	err := router.TryHandle("GET", "/users", simpleHandler("other"))
+	assert.Error(t, err)
+
+	// Without normalization both spellings register separately
+	plain := New()
+	assert.NoError(t, plain.TryHandle("GET", "/users/", simpleHandler("slash")))
+	assert.NoError(t, plain.TryHandle("GET", "/users", simpleHandler("bare")))
+}
+
+func TestRouter_ConcurrentRegistration(t *testing.T) {
+	router := New()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				path := fmt.Sprintf("/plugin%d/route%d/:id", g, i)
+				router.HandleRoute("GET", path, paramHandler).
+					Name(fmt.Sprintf("plugin%d.route%d", g, i)).
+					Where("id", IsNumeric)
+
+				// Lookups, settings and requests run alongside registration
+				router.AllowedMethods(path)
+				router.SetConstraintFailStatus(http.StatusNotFound)
+				router.SetDescribeOptions(i%2 == 0)
+				router.SetMethodNotAllowedHandler(nil)
+				for _, info := range router.GetRoutes() {
+					_ = info.Path
+				}
+
+				req := httptest.NewRequest("OPTIONS", fmt.Sprintf("/plugin%d/route%d/abc", g, i), nil)
+				resp := httptest.NewRecorder()
+				c := context.Acquire()
+				c.Reset(resp, req)
+				router.ServeHTTP(resp, req, c)
+				context.Release(c)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	assert.Len(t, router.GetRoutes(), 200)
+	for g := 0; g < 8; g++ {
+		for i := 0; i < 25; i++ {
+			url, err := router.URLStrict(fmt.Sprintf("plugin%d.route%d", g, i), map[string]string{"id": "7"})
+			assert.NoError(t, err)
+
+			req := httptest.NewRequest("GET", url, nil)
+			resp := httptest.NewRecorder()
+			c := context.Acquire()
+			c.Reset(resp, req)
+			router.ServeHTTP(resp, req, c)
+			context.Release(c)
+			assert.Equal(t, http.StatusOK, resp.Code, url)
+		}
+	}
+}
+
+func TestRouter_NotFoundForPrefix(t *testing.T) {
+	router := New()
+	router.Handle("GET", "/api/users", simpleHandler("users"))
+
+	router.SetNotFoundHandler(func(c *context.Context) error {
+		return c.String(http.StatusNotFound, "<h1>Page not found</h1>", response.WithContentType("text/html"))
+	})
+	router.SetNotFoundHandlerForPrefix("/api", func(c *context.Context) error {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+	})
+	router.SetNotFoundHandlerForPrefix("/api/v2/", func(c *context.Context) error {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "v2 not found"})
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		resp := httptest.NewRecorder()
+		c := context.Acquire()
+		defer context.Release(c)
+		c.Reset(resp, req)
+		router.ServeHTTP(resp, req, c)
+		return resp
+	}
+
+	resp := serve("/api/missing")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Contains(t, resp.Header().Get("Content-Type"), "application/json")
+	assert.JSONEq(t, `{"error":"not found"}`, resp.Body.String())
+
+	resp = serve("/api/v2/missing")
+	assert.JSONEq(t, `{"error":"v2 not found"}`, resp.Body.String())
+
+	for _, path := range []string{"/missing", "/apiary"} {
+		resp = serve(path)
+		assert.Equal(t, http.StatusNotFound, resp.Code, path)
+		assert.Contains(t, resp.Header().Get("Content-Type"), "text/html", path)
+	}
+}
+
+func TestRouter_UsePreRouting(t *testing.T) {
+	router := New()
+
+	var logged, authed []string
+	router.UsePreRouting(func(c *context.Context) error {
+		err := c.Next()
+		logged = append(logged, c.Request.URL.Path)
+		return err
+	})
+	auth := func(c *context.Context) error {
+		authed = append(authed, c.Request.URL.Path)
+		return c.Next()
+	}
+	router.Handle("GET", "/account", simpleHandler("account"), auth)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		resp := httptest.NewRecorder()
+		c := context.Acquire()
+		defer context.Release(c)
+		c.Reset(resp, req)
+		router.ServeHTTP(resp, req, c)
+		return resp
+	}
+
+	resp := serve("GET", "/account")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "account", resp.Body.String())
+
+	resp = serve("GET", "/missing")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serve("DELETE", "/account")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+
+	assert.Equal(t, []string{"/account", "/missing", "/account"}, logged)
+	assert.Equal(t, []string{"/account"}, authed)
+}
+
+func TestRouter_PreRoutingHeadersOnErrors(t *testing.T) {
+	router := New()
+	router.Handle("GET", "/users", simpleHandler("users"))
+
+	cors := response.NewCORSMiddleware()
+	cors.SetAllowedOrigins("https://app.example.com")
+	security := response.NewSecurityMiddleware()
+	router.UsePreRouting(func(c *context.Context) error {
+		cors.Wrap(c.Writer, c.Request)
+		security.Wrap(c.Writer, c.Request)
+		return c.Next()
+	})
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		resp := httptest.NewRecorder()
+		c := context.Acquire()
+		defer context.Release(c)
+		c.Reset(resp, req)
+		router.ServeHTTP(resp, req, c)
+		return resp
+	}
+
+	for _, tt := range []struct {
+		method, path string
+		code         int
+	}{
+		{"GET", "/missing", http.StatusNotFound},
+		{"DELETE", "/users", http.StatusMethodNotAllowed},
+		{"GET", "/users", http.StatusOK},
+	} {
+		resp := serve(tt.method, tt.path)
+		assert.Equal(t, tt.code, resp.Code, tt.path)
+		assert.Equal(t, "https://app.example.com", resp.Header().Get("Access-Control-Allow-Origin"), tt.path)
+		assert.NotEmpty(t, resp.Header().Get("Access-Control-Allow-Methods"), tt.path)
+		assert.Equal(t, "nosniff", resp.Header().Get("X-Content-Type-Options"), tt.path)
+	}
+}
+
+func TestRouter_ConstraintDispatch(t *testing.T) {
+	router := New()
+
+	router.NewRoute().Method("GET").Path("/users/:id").
+		Handler(func(c *context.Context) error { return c.String(http.StatusOK, "id "+c.Param("id")) }).
+		WhereNumber("id").
+		Build()
+	router.NewRoute().Method("GET").Path("/users/:name").
+		Handler(func(c *context.Context) error { return c.String(http.StatusOK, "name "+c.Param("name")) }).
+		Name("users.byName").
+		WhereAlpha("name").
+		Build()
+
+	// Same path for another method, without the numeric constraint
+	router.Handle("DELETE", "/users/:id", paramHandler)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		resp := httptest.NewRecorder()
+		c := context.Acquire()
+		defer context.Release(c)
+		c.Reset(resp, req)
+		router.ServeHTTP(resp, req, c)
+		return resp
+	}
+
+	resp := serve("GET", "/users/42")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "id 42", resp.Body.String())
+
+	resp = serve("GET", "/users/alice")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "name alice", resp.Body.String())
+
+	// Neither GET route accepts it, but the unconstrained DELETE does
+	resp = serve("GET", "/users/alice42")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	assert.Equal(t, "DELETE, OPTIONS", resp.Header().Get("Allow"))
+
+	resp = serve("DELETE", "/users/alice")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "alice", resp.Body.String())
+
+	assert.Empty(t, router.Validate())
+
+	// Alternates are walked and described alongside their route
+	var walked []string
+	router.Walk(func(method, path string, handler context.HandlerFunc) error {
+		if method == "GET" {
+			walked = append(walked, path)
+		}
+		return nil
+	})
+	assert.Equal(t, []string{"/users/:id", "/users/:name"}, walked)
+
+	router.SetDescribeOptions(true)
+	resp = serve("OPTIONS", "/users/alice")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"path":"/users/:name"`)
+
+	// Behind the unconstrained DELETE route an alternate could never be
+	// reached, so it still conflicts
+	assert.Panics(t, func() {
+		router.Handle("DELETE", "/users/:name", paramHandler)
+	})
+}
+
+func TestRouter_ReRegisterClearsConstraints(t *testing.T) {
+	router := New()
+	router.HandleRoute("GET", "/users/:id", paramHandler).Where("id", IsNumeric)
+	router.HandleRoute("GET", "/users/:name", paramHandler).Where("name", IsAlpha)
+
+	serve := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		resp := httptest.NewRecorder()
+		c := context.Acquire()
+		defer context.Release(c)
+		c.Reset(resp, req)
+		router.ServeHTTP(resp, req, c)
+		return resp.Code
+	}
+	assert.Equal(t, http.StatusNotFound, serve("/users/abc42"))
+
+	// Replacing the alternate drops its constraint
+	router.Handle("GET", "/users/:name", paramHandler)
+	assert.Equal(t, http.StatusOK, serve("/users/abc42"))
+
+	// Replacing the route itself drops its constraint too
+	router = New()
+	router.HandleRoute("GET", "/users/:id", paramHandler).Where("id", IsNumeric)
+	assert.Equal(t, http.StatusNotFound, serve("/users/abc"))
+	router.Handle("GET", "/users/:id", paramHandler)
+	assert.Equal(t, http.StatusOK, serve("/users/abc"))
+}
+
+func TestRouter_Validate(t *testing.T) {
+	router := New()
+	router.HandleRoute("GET", "/users/:id", paramHandler).Name("users.show")
+	router.HandleRoute("GET", "/static/*filepath", simpleHandler("file")).Name("static")
+	router.Handle("POST", "/users", simpleHandler("create"))
+
+	assert.Empty(t, router.Validate())
+
+	// Named route whose path was never registered
+	router.storeRouteInfo(&RouteInfo{Method: "GET", Path: "/ghost", Name: "ghost"})
+	router.storeRouteInfo(&RouteInfo{Method: "DELETE", Path: "/users/:id", Name: "users.delete"})
+
+	// Corrupt the GET tree: a handler-less leaf and a static sibling
+	// next to the :id wildcard
+	root := router.trees["GET"]
+	users := root.children[0]
+	for _, child := range root.children {
+		if child.path == "users/" {
+			users = child
+		}
+	}
+	users.children = append(users.children, &node{path: "new"})
+
+	errs := router.Validate()
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	assert.Len(t, errs, 4, messages)
+	assert.Contains(t, messages, "GET /users/new: leaf node has no handler")
+	assert.Contains(t, messages, "GET /users/: wildcard child shadows 1 static sibling(s)")
+	assert.Contains(t, messages, "named route 'ghost': GET /ghost is not registered")
+	assert.Contains(t, messages, "named route 'users.delete': no DELETE routes registered")
+}
+
 // Benchmark tests
 func BenchmarkRouterStaticRoute(b *testing.B) {
 	router := New()
@@ -177,3 +742,46 @@ func BenchmarkRouterWildcardRoute(b *testing.B) {
 		context.Release(c)
 	}
 }
+
+func BenchmarkRouterDeepNesting(b *testing.B) {
+	router := New()
+
+	path := "/"
+	for i := 0; i < 20; i++ {
+		path += fmt.Sprintf("level%d/", i)
+	}
+	router.Handle("GET", path+":id", func(c *context.Context) error {
+		return c.String(http.StatusOK, c.Param("id"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, path+"finalvalue", nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		c := context.Acquire()
+		c.Reset(w, req)
+		router.ServeHTTP(w, req, c)
+		context.Release(c)
+	}
+}
+
+func BenchmarkRouterLongestPath(b *testing.B) {
+	router := New()
+
+	longPath := "/very/long/path/with/many/segments/that/could/potentially/slow/down/routing/performance/test/case"
+	router.Handle("GET", longPath, func(c *context.Context) error {
+		return c.String(http.StatusOK, "found")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, longPath, nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		c := context.Acquire()
+		c.Reset(w, req)
+		router.ServeHTTP(w, req, c)
+		context.Release(c)
+	}
+}
@@ -1,6 +1,8 @@
 package router
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/aliwert/go-wolf/pkg/context"
@@ -14,8 +16,10 @@ type node struct {
 	indices   string
 	children  []*node
 	handle    context.HandlerFunc
+	fullPath  string
 	priority  uint32
 	maxParams uint8
+	maxDepth  int // catch-all segment limit, 0 means unbounded
 }
 
 type nodeType uint8
@@ -60,6 +64,7 @@ walk:
 				indices:   n.indices,
 				children:  n.children,
 				handle:    n.handle,
+				fullPath:  n.fullPath,
 				priority:  n.priority - 1,
 				maxParams: n.maxParams,
 			}
@@ -75,6 +80,7 @@ walk:
 			n.indices = string([]byte{n.path[i]})
 			n.path = path[:i]
 			n.handle = nil
+			n.fullPath = ""
 			n.wildChild = false
 		}
 
@@ -101,6 +107,13 @@ walk:
 					(len(n.path) >= len(path) || path[len(n.path)] == '/') {
 					continue walk
 				} else {
+					// Catch-all conflict
+					if n.nType == catchAll || strings.HasPrefix(path, "*") {
+						panic("catch-all conflict: new path '" + fullPath +
+							"' conflicts with existing route '" + n.pattern() +
+							"' at the same position")
+					}
+
 					// Wildcard conflict
 					pathSeg := path
 					if n.nType != catchAll {
@@ -152,6 +165,7 @@ walk:
 		// Otherwise add handle to current node
 		// If a handle already exists, overwrite it (instead of panicking)
 		n.handle = handle
+		n.fullPath = fullPath
 		return
 	}
 }
@@ -179,6 +193,11 @@ func (n *node) insertChild(numParams uint8, path, fullPath string, handle contex
 		// Check if this Node existing children which would be
 		// unreachable if we insert the wildcard here
 		if len(n.children) > 0 {
+			if wildcard[0] == '*' {
+				panic("catch-all conflict: new path '" + fullPath +
+					"' conflicts with existing route '" + n.pattern() +
+					"' at the same position")
+			}
 			panic("wildcard segment '" + wildcard +
 				"' conflicts with existing children in path '" + fullPath + "'")
 		}
@@ -218,6 +237,7 @@ func (n *node) insertChild(numParams uint8, path, fullPath string, handle contex
 
 			// Otherwise we're done. Insert the handle in the new leaf
 			n.handle = handle
+			n.fullPath = fullPath
 			return
 		}
 
@@ -227,7 +247,8 @@ func (n *node) insertChild(numParams uint8, path, fullPath string, handle contex
 		}
 
 		if len(n.path) > 0 && n.path[len(n.path)-1] == '/' {
-			panic("catch-all conflicts with existing handle for the path segment root in path '" + fullPath + "'")
+			panic("catch-all conflicts with existing handle '" + n.pattern() +
+				"' for the path segment root in path '" + fullPath + "'")
 		}
 
 		// Currently fixed width 1 for '/'
@@ -238,6 +259,9 @@ func (n *node) insertChild(numParams uint8, path, fullPath string, handle contex
 
 		n.path = path[:i]
 
+		// Strip catch-all options such as {maxdepth=3}
+		catchPath, maxDepth := parseCatchAllOptions(path[i:], fullPath)
+
 		// First node: catchAll node with empty path
 		child := &node{
 			wildChild: true,
@@ -252,11 +276,13 @@ func (n *node) insertChild(numParams uint8, path, fullPath string, handle contex
 
 		// Second node: node holding the variable
 		child = &node{
-			path:      path[i:],
+			path:      catchPath,
 			nType:     catchAll,
 			handle:    handle,
+			fullPath:  fullPath,
 			priority:  1,
 			maxParams: 1,
+			maxDepth:  maxDepth,
 		}
 		n.children = []*node{child}
 
@@ -266,10 +292,106 @@ func (n *node) insertChild(numParams uint8, path, fullPath string, handle contex
 	// If no wildcard was found, simply insert the path and handle
 	n.path = path
 	n.handle = handle
+	n.fullPath = fullPath
+}
+
+// parseCatchAllOptions splits a catch-all segment such as
+// "/*path{maxdepth=3}" into its path and depth limit
+func parseCatchAllOptions(segment, fullPath string) (string, int) {
+	open := strings.IndexByte(segment, '{')
+	if open < 0 {
+		return segment, 0
+	}
+
+	option := segment[open:]
+	if !strings.HasPrefix(option, "{maxdepth=") || option[len(option)-1] != '}' {
+		panic("invalid catch-all option '" + option + "' in path '" + fullPath + "'")
+	}
+
+	depth, err := strconv.Atoi(option[len("{maxdepth=") : len(option)-1])
+	if err != nil || depth < 1 {
+		panic("catch-all maxdepth must be a positive integer in path '" + fullPath + "'")
+	}
+
+	if open <= 2 { // "/*" without a name
+		panic("wildcards must be named with a non-empty name in path '" + fullPath + "'")
+	}
+
+	return segment[:open], depth
+}
+
+// walk visits every node with a handle, reconstructing its full path
+// from the node segments
+func (n *node) walk(prefix string, fn func(path string, handle context.HandlerFunc) error) error {
+	path := prefix + n.path
+	if n.handle != nil {
+		if err := fn(path, n.handle); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range n.children {
+		if err := child.walk(path, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// check reports structural problems at or below this node: wildcard
+// children next to static siblings, which would shadow them, and leaves
+// without a handle
+func (n *node) check(method, prefix string) []error {
+	var errs []error
+	path := prefix + n.path
+
+	if len(n.children) == 0 && n.handle == nil {
+		errs = append(errs, fmt.Errorf("%s %s: leaf node has no handler", method, path))
+	}
+
+	wildcards := 0
+	for _, child := range n.children {
+		if child.nType == param || child.nType == catchAll {
+			wildcards++
+		}
+	}
+	if wildcards > 0 && len(n.children) > 1 {
+		errs = append(errs, fmt.Errorf("%s %s: wildcard child shadows %d static sibling(s)",
+			method, path, len(n.children)-wildcards))
+	}
+	if n.wildChild && wildcards == 0 {
+		errs = append(errs, fmt.Errorf("%s %s: marked as having a wildcard child but has none", method, path))
+	}
+
+	for _, child := range n.children {
+		errs = append(errs, child.check(method, path)...)
+	}
+	return errs
+}
+
+// pattern returns the full path of a route registered at or below this node
+func (n *node) pattern() string {
+	for n != nil {
+		if n.fullPath != "" {
+			return n.fullPath
+		}
+		if len(n.children) == 0 {
+			break
+		}
+		n = n.children[0]
+	}
+	return ""
 }
 
 // getValue returns the handle registered with the given path
 func (n *node) getValue(path string) (handle context.HandlerFunc, params map[string]string, tsr bool) {
+	handle, params, _, tsr = n.lookup(path)
+	return
+}
+
+// lookup is getValue that also returns the pattern of the matched route
+func (n *node) lookup(path string) (handle context.HandlerFunc, params map[string]string, route string, tsr bool) {
 walk:
 	for {
 		prefix := n.path
@@ -326,6 +448,7 @@ walk:
 					}
 
 					if handle = n.handle; handle != nil {
+						route = n.fullPath
 						return
 					} else if len(n.children) == 1 {
 						// No handle found. Check if a handle for this path + a
@@ -337,6 +460,11 @@ walk:
 					return
 
 				case catchAll:
+					// Enforce the catch-all depth limit
+					if n.maxDepth > 0 && segmentCount(path) > n.maxDepth {
+						return
+					}
+
 					// Save param value
 					if params == nil {
 						params = make(map[string]string)
@@ -344,6 +472,7 @@ walk:
 					params[n.path[2:]] = path
 
 					handle = n.handle
+					route = n.fullPath
 					return
 
 				default:
@@ -354,6 +483,7 @@ walk:
 			// We should have reached the node containing the handle.
 			// Check if this node has a handle registered.
 			if handle = n.handle; handle != nil {
+				route = n.fullPath
 				return
 			}
 
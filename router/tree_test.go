@@ -2,6 +2,7 @@ package router
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/aliwert/go-wolf/pkg/context"
@@ -288,3 +289,166 @@ func TestFindWildcard(t *testing.T) {
 		}
 	}
 }
+
+func TestNodeCatchAllConflicts(t *testing.T) {
+	handler := func(c *context.Context) error {
+		return nil
+	}
+
+	conflicting := []struct {
+		existing string
+		newPath  string
+	}{
+		{"/files/*path", "/files/:name"},
+		{"/files/:name", "/files/*path"},
+		{"/files/*path", "/files/static"},
+		{"/files/static", "/files/*path"},
+		{"/files/*path", "/files/*other"},
+	}
+
+	for _, tt := range conflicting {
+		t.Run(tt.existing+" vs "+tt.newPath, func(t *testing.T) {
+			root := &node{}
+			root.addRoute(tt.existing, handler)
+
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					t.Fatalf("Expected panic registering '%s' after '%s'", tt.newPath, tt.existing)
+				}
+				msg, _ := rec.(string)
+				if !strings.Contains(msg, tt.existing) || !strings.Contains(msg, tt.newPath) {
+					t.Errorf("Expected panic to name both patterns, got: %s", msg)
+				}
+			}()
+
+			root.addRoute(tt.newPath, handler)
+		})
+	}
+
+	nonConflicting := [][]string{
+		{"/files/*path", "/other/*path"},
+		{"/files/:name", "/files/:name/*path"},
+		{"/static/*filepath", "/files/:name"},
+	}
+
+	for _, paths := range nonConflicting {
+		t.Run(strings.Join(paths, " and "), func(t *testing.T) {
+			root := &node{}
+			for _, p := range paths {
+				root.addRoute(p, handler)
+			}
+		})
+	}
+}
+
+func TestNodeCatchAllMaxDepth(t *testing.T) {
+	root := &node{}
+
+	handler := func(c *context.Context) error {
+		return c.String(http.StatusOK, "file")
+	}
+
+	root.addRoute("/files/*path{maxdepth=3}", handler)
+	root.addRoute("/assets/*path", handler)
+
+	tests := []struct {
+		path     string
+		found    bool
+		expected string
+	}{
+		{"/files/a", true, "/a"},
+		{"/files/a/b/c", true, "/a/b/c"},
+		{"/files/a/b/c/", true, "/a/b/c/"},
+		{"/files/a/b/c/d", false, ""},
+		{"/assets/a/b/c/d/e", true, "/a/b/c/d/e"},
+	}
+
+	for _, test := range tests {
+		handle, params, _ := root.getValue(test.path)
+		if (handle != nil) != test.found {
+			t.Errorf("getValue(%s) found = %v, expected %v", test.path, handle != nil, test.found)
+			continue
+		}
+		if test.found && params["path"] != test.expected {
+			t.Errorf("getValue(%s) path = %q, expected %q", test.path, params["path"], test.expected)
+		}
+	}
+}
+
+func TestNodeCatchAllInvalidOptions(t *testing.T) {
+	paths := []string{
+		"/files/*path{maxdepth=0}",
+		"/files/*path{maxdepth=x}",
+		"/files/*path{depth=3}",
+		"/files/*{maxdepth=3}",
+	}
+
+	for _, path := range paths {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected panic for path '%s'", path)
+				}
+			}()
+			root := &node{}
+			root.addRoute(path, func(c *context.Context) error { return nil })
+		}()
+	}
+}
+
+func TestNodeStaticChainsCompressed(t *testing.T) {
+	root := &node{}
+
+	handler := func(c *context.Context) error {
+		return c.String(http.StatusOK, "test")
+	}
+
+	deep := "/"
+	for i := 0; i < 20; i++ {
+		deep += "level" + string(rune('a'+i)) + "/"
+	}
+
+	routes := []string{
+		deep + ":id",
+		"/very/long/path/with/many/segments/that/could/potentially/slow/down/routing",
+		"/users/:id/posts/:pid/comments",
+		"/users/:id/profile",
+	}
+	for _, route := range routes {
+		root.addRoute(route, handler)
+	}
+
+	// A handle-less static node with a single static child could be
+	// merged into it; lookups should never have to walk such chains
+	var check func(n *node)
+	check = func(n *node) {
+		if n.nType == static && n.handle == nil && !n.wildChild && len(n.children) == 1 &&
+			n.children[0].nType == static {
+			t.Errorf("Uncompressed static chain at '%s' -> '%s'", n.path, n.children[0].path)
+		}
+		for _, child := range n.children {
+			check(child)
+		}
+	}
+	check(root)
+
+	lookups := map[string]map[string]string{
+		deep + "42": {"id": "42"},
+		"/very/long/path/with/many/segments/that/could/potentially/slow/down/routing": nil,
+		"/users/7/posts/9/comments": {"id": "7", "pid": "9"},
+		"/users/7/profile":          {"id": "7"},
+	}
+	for path, expected := range lookups {
+		handle, params, _ := root.getValue(path)
+		if handle == nil {
+			t.Errorf("Expected handler for '%s'", path)
+			continue
+		}
+		for key, value := range expected {
+			if params[key] != value {
+				t.Errorf("Expected param %s=%s for '%s', got %v", key, value, path, params)
+			}
+		}
+	}
+}
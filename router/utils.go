@@ -66,12 +66,10 @@ func (ru *RouteUtils) ExtractParams(pattern, path string) map[string]string {
 
 	for i, part := range patternParts {
 		if strings.HasPrefix(part, ":") {
-			paramName := part[1:]
-			params[paramName] = pathParts[i]
+			params[paramName(part[1:])] = pathParts[i]
 		} else if strings.HasPrefix(part, "*") {
-			paramName := part[1:]
 			// Join remaining path parts for wildcard
-			params[paramName] = strings.Join(pathParts[i:], "/")
+			params[paramName(part[1:])] = strings.Join(pathParts[i:], "/")
 			break
 		}
 	}
@@ -161,9 +159,9 @@ func (ru *RouteUtils) ParseRoutePattern(pattern string) *RoutePattern {
 
 	for _, part := range parts {
 		if strings.HasPrefix(part, ":") {
-			params = append(params, part[1:])
+			params = append(params, paramName(part[1:]))
 		} else if strings.HasPrefix(part, "*") {
-			wildcards = append(wildcards, part[1:])
+			wildcards = append(wildcards, paramName(part[1:]))
 		} else if part != "" {
 			staticParts = append(staticParts, part)
 		}
@@ -217,6 +215,15 @@ func (ru *RouteUtils) GenerateURL(pattern string, params map[string]string) (str
 	return result, nil
 }
 
+// GenerateURLStrict generates a URL like GenerateURL, additionally
+// validating each parameter against its constraint
+func (ru *RouteUtils) GenerateURLStrict(pattern string, params map[string]string, constraints map[string]Constraint) (string, error) {
+	if err := NewConstraintValidator().ValidateParams(params, constraints); err != nil {
+		return "", err
+	}
+	return ru.GenerateURL(pattern, params)
+}
+
 // RouteDebugInfo provides debugging information about routes
 type RouteDebugInfo struct {
 	Method      string
@@ -265,21 +272,7 @@ func (mc *MiddlewareChain) Add(middleware ...context.HandlerFunc) *MiddlewareCha
 
 // Build builds the final handler with all middleware applied
 func (mc *MiddlewareChain) Build(handler context.HandlerFunc) context.HandlerFunc {
-	result := handler
-
-	// Apply middleware in reverse order
-	for i := len(mc.middleware) - 1; i >= 0; i-- {
-		mw := mc.middleware[i]
-		next := result
-		result = func(mw context.HandlerFunc, next context.HandlerFunc) context.HandlerFunc {
-			return func(c *context.Context) error {
-				c.SetNext(next)
-				return mw(c)
-			}
-		}(mw, next)
-	}
-
-	return result
+	return chainMiddleware(mc.middleware, handler)
 }
 
 // Length returns the number of middleware in the chain
@@ -598,3 +598,20 @@ func BenchmarkConstraintValidator_ValidateParams(b *testing.B) {
 		validator.ValidateParams(params, constraints)
 	}
 }
+
+func TestRouteUtils_GenerateURLStrict(t *testing.T) {
+	utils := NewRouteUtils()
+	constraints := map[string]Constraint{"id": IsNumeric}
+
+	result, err := utils.GenerateURLStrict("/users/:id", map[string]string{"id": "42"}, constraints)
+	if err != nil {
+		t.Fatalf("GenerateURLStrict unexpected error: %v", err)
+	}
+	if result != "/users/42" {
+		t.Errorf("GenerateURLStrict = %s, expected /users/42", result)
+	}
+
+	if _, err := utils.GenerateURLStrict("/users/:id", map[string]string{"id": "abc"}, constraints); err == nil {
+		t.Error("GenerateURLStrict expected error for non-numeric id")
+	}
+}